@@ -28,7 +28,7 @@ func defaultAnalyticsParams(params *AnalyticsParams) *AnalyticsParams {
 func (s *AnalyticsService) Traffic(ctx context.Context, params *AnalyticsParams) (*TimeSeriesResponse, error) {
 	params = defaultAnalyticsParams(params)
 	var result TimeSeriesResponse
-	err := s.client.doRequest(ctx, "POST", "traffic", params, &result)
+	err := s.client.doRequest(ctx, "Analytics.Traffic", "POST", "traffic", params, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -39,7 +39,7 @@ func (s *AnalyticsService) Traffic(ctx context.Context, params *AnalyticsParams)
 func (s *AnalyticsService) TrafficTotal(ctx context.Context, params *AnalyticsParams) (*TotalResponse, error) {
 	params = defaultAnalyticsParams(params)
 	var result TotalResponse
-	err := s.client.doRequest(ctx, "POST", "traffic/period-total", params, &result)
+	err := s.client.doRequest(ctx, "Analytics.TrafficTotal", "POST", "traffic/period-total", params, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -50,7 +50,7 @@ func (s *AnalyticsService) TrafficTotal(ctx context.Context, params *AnalyticsPa
 func (s *AnalyticsService) Requests(ctx context.Context, params *AnalyticsParams) (*TimeSeriesResponse, error) {
 	params = defaultAnalyticsParams(params)
 	var result TimeSeriesResponse
-	err := s.client.doRequest(ctx, "POST", "requests", params, &result)
+	err := s.client.doRequest(ctx, "Analytics.Requests", "POST", "requests", params, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -61,7 +61,7 @@ func (s *AnalyticsService) Requests(ctx context.Context, params *AnalyticsParams
 func (s *AnalyticsService) RequestsTotal(ctx context.Context, params *AnalyticsParams) (*TotalResponse, error) {
 	params = defaultAnalyticsParams(params)
 	var result TotalResponse
-	err := s.client.doRequest(ctx, "POST", "requests/period-total", params, &result)
+	err := s.client.doRequest(ctx, "Analytics.RequestsTotal", "POST", "requests/period-total", params, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -72,7 +72,7 @@ func (s *AnalyticsService) RequestsTotal(ctx context.Context, params *AnalyticsP
 func (s *AnalyticsService) DomainBreakdown(ctx context.Context, params *AnalyticsParams) (*DomainBreakdownResponse, error) {
 	params = defaultAnalyticsParams(params)
 	var result DomainBreakdownResponse
-	err := s.client.doRequest(ctx, "POST", "domain-breakdown", params, &result)
+	err := s.client.doRequest(ctx, "Analytics.DomainBreakdown", "POST", "domain-breakdown", params, &result)
 	if err != nil {
 		return nil, err
 	}
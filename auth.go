@@ -27,7 +27,7 @@ type LoginParams struct {
 // Register creates a new account.
 func (s *AuthService) Register(ctx context.Context, params RegisterParams) (*RegisterResponse, error) {
 	var result RegisterResponse
-	err := s.client.doRequest(ctx, "POST", "auth/register", params, &result)
+	err := s.client.doRequest(ctx, "Auth.Register", "POST", "auth/register", params, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -37,7 +37,7 @@ func (s *AuthService) Register(ctx context.Context, params RegisterParams) (*Reg
 // Login authenticates a user.
 func (s *AuthService) Login(ctx context.Context, params LoginParams) (*LoginResponse, error) {
 	var result LoginResponse
-	err := s.client.doRequest(ctx, "POST", "auth/login", params, &result)
+	err := s.client.doRequest(ctx, "Auth.Login", "POST", "auth/login", params, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -47,7 +47,7 @@ func (s *AuthService) Login(ctx context.Context, params LoginParams) (*LoginResp
 // User returns the authenticated user.
 func (s *AuthService) User(ctx context.Context) (*User, error) {
 	var result User
-	err := s.client.doRequest(ctx, "GET", "auth/user", nil, &result)
+	err := s.client.doRequest(ctx, "Auth.User", "GET", "auth/user", nil, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -56,13 +56,13 @@ func (s *AuthService) User(ctx context.Context) (*User, error) {
 
 // Logout invalidates the current session.
 func (s *AuthService) Logout(ctx context.Context) error {
-	return s.client.doRequest(ctx, "POST", "auth/logout", nil, nil)
+	return s.client.doRequest(ctx, "Auth.Logout", "POST", "auth/logout", nil, nil)
 }
 
 // SupportedProviders returns the list of supported OAuth providers.
 func (s *AuthService) SupportedProviders(ctx context.Context) ([]SupportedProvider, error) {
 	var result []SupportedProvider
-	err := s.client.doRequest(ctx, "GET", "auth/supported-providers", nil, &result)
+	err := s.client.doRequest(ctx, "Auth.SupportedProviders", "GET", "auth/supported-providers", nil, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -72,7 +72,7 @@ func (s *AuthService) SupportedProviders(ctx context.Context) ([]SupportedProvid
 // SocialAccounts returns the user's connected social accounts.
 func (s *AuthService) SocialAccounts(ctx context.Context) ([]SocialAccount, error) {
 	var result []SocialAccount
-	err := s.client.doRequest(ctx, "GET", "auth/social-accounts", nil, &result)
+	err := s.client.doRequest(ctx, "Auth.SocialAccounts", "GET", "auth/social-accounts", nil, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -81,13 +81,21 @@ func (s *AuthService) SocialAccounts(ctx context.Context) ([]SocialAccount, erro
 
 // DisconnectSocial removes a connected social account.
 func (s *AuthService) DisconnectSocial(ctx context.Context, provider string) error {
-	return s.client.doRequest(ctx, "DELETE", "auth/social-accounts/"+provider, nil, nil)
+	path, err := buildPath("auth/social-accounts/{provider}", provider)
+	if err != nil {
+		return err
+	}
+	return s.client.doRequest(ctx, "Auth.DisconnectSocial", "DELETE", path, nil, nil)
 }
 
 // OAuthRedirect returns the OAuth redirect URL for a provider.
 func (s *AuthService) OAuthRedirect(ctx context.Context, provider string) (any, error) {
 	var result any
-	err := s.client.doRequest(ctx, "GET", "auth/"+provider+"/redirect", nil, &result)
+	path, err := buildPath("auth/{provider}/redirect", provider)
+	if err != nil {
+		return nil, err
+	}
+	err = s.client.doRequest(ctx, "Auth.OAuthRedirect", "GET", path, nil, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,330 @@
+package proxyhat
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is matched with errors.Is by errors returned for calls
+// rejected by an open circuit breaker. See WithCircuitBreaker.
+var ErrCircuitOpen = errors.New("proxyhat: circuit open")
+
+// CircuitOpenError is returned without contacting the API while the circuit
+// breaker for the call's endpoint group is open.
+type CircuitOpenError struct {
+	Group string
+	// RetryAt is when the breaker lets a trial call through, or zero if a
+	// trial call is already in flight.
+	RetryAt time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("proxyhat: circuit open for %q", e.Group)
+}
+
+// Is makes errors.Is(err, ErrCircuitOpen) report true.
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+const (
+	// CircuitClosed lets calls through and counts failures.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects calls until the cool-down has passed.
+	CircuitOpen
+	// CircuitHalfOpen lets a limited number of trial calls through; their
+	// outcome closes or reopens the circuit.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+// CircuitBreakerConfig configures WithCircuitBreaker. Zero fields take
+// their defaults.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures that opens
+	// the circuit. Defaults to 5.
+	FailureThreshold int
+	// CoolDown is how long the circuit stays open before letting trial
+	// calls through. Defaults to 30 seconds.
+	CoolDown time.Duration
+	// HalfOpenRequests is how many trial calls may run at once while the
+	// circuit is half-open. Defaults to 1.
+	HalfOpenRequests int
+	// Group maps an API path such as "sub-users/123" to its endpoint
+	// group. Each group has its own circuit. Defaults to the first path
+	// segment, e.g. "sub-users".
+	Group func(path string) string
+	// IsFailure reports whether a call's error counts against the circuit.
+	// Defaults to transport failures and 408 and 5xx responses; client
+	// errors such as 404 or 429 show that the API is up. Calls whose own
+	// context ended and errors raised before sending, such as rate limiter
+	// waits, are never counted.
+	IsFailure func(err error) bool
+	// OnStateChange is called after a circuit changes state, e.g. to
+	// alert. It must not block.
+	OnStateChange func(group string, from, to CircuitState)
+}
+
+// WithCircuitBreaker fails calls fast with ErrCircuitOpen while the API is
+// failing, instead of letting each one wait for a timeout. Each endpoint
+// group has its own circuit. A call retried under WithRetryPolicy counts
+// once, with its final outcome. Client.Health reports the circuits.
+func WithCircuitBreaker(cfg CircuitBreakerConfig) Option {
+	return func(c *Client) {
+		if cfg.FailureThreshold <= 0 {
+			cfg.FailureThreshold = 5
+		}
+		if cfg.CoolDown <= 0 {
+			cfg.CoolDown = 30 * time.Second
+		}
+		if cfg.HalfOpenRequests <= 0 {
+			cfg.HalfOpenRequests = 1
+		}
+		if cfg.Group == nil {
+			cfg.Group = defaultCircuitGroup
+		}
+		if cfg.IsFailure == nil {
+			cfg.IsFailure = defaultCircuitFailure
+		}
+		c.breaker = &circuitBreaker{cfg: cfg, now: time.Now, circuits: map[string]*circuit{}}
+	}
+}
+
+func defaultCircuitGroup(path string) string {
+	group, _, _ := strings.Cut(strings.Trim(path, "/"), "/")
+	return group
+}
+
+func defaultCircuitFailure(err error) bool {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500 || apiErr.StatusCode == http.StatusRequestTimeout
+	}
+	var transportErr *TransportError
+	return errors.As(err, &transportErr)
+}
+
+// reachedAPI reports whether err came from sending a request, rather than
+// from the client refusing to send it.
+func reachedAPI(err error) bool {
+	var apiErr *Error
+	var transportErr *TransportError
+	var decodeErr *DecodeError
+	return errors.As(err, &apiErr) || errors.As(err, &transportErr) || errors.As(err, &decodeErr)
+}
+
+// CircuitStatus is a snapshot of one endpoint group's circuit.
+type CircuitStatus struct {
+	Group string
+	State CircuitState
+	// Failures is the number of consecutive failures counted so far.
+	Failures int
+	// OpenedAt is when the circuit last opened, or zero if it never has.
+	OpenedAt time.Time
+}
+
+// ClientHealth is a snapshot of the client's view of the API, returned by
+// Client.Health.
+type ClientHealth struct {
+	// Circuits lists the circuit of every endpoint group called so far,
+	// sorted by group. It is empty without WithCircuitBreaker.
+	Circuits []CircuitStatus
+	// RateLimit is the last rate limit state reported by the API.
+	RateLimit RateLimitState
+}
+
+// Healthy reports whether no circuit is open or half-open.
+func (h ClientHealth) Healthy() bool {
+	for _, c := range h.Circuits {
+		if c.State != CircuitClosed {
+			return false
+		}
+	}
+	return true
+}
+
+// Health returns a snapshot of the circuit breakers and rate limit state.
+func (c *Client) Health() ClientHealth {
+	h := ClientHealth{RateLimit: c.RateLimitState()}
+	if c.breaker != nil {
+		h.Circuits = c.breaker.snapshot()
+	}
+	return h
+}
+
+type circuitBreaker struct {
+	cfg CircuitBreakerConfig
+	now func() time.Time
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+type circuit struct {
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	// probes is the number of trial calls in flight while half-open.
+	probes int
+}
+
+type circuitTransition struct {
+	group    string
+	from, to CircuitState
+}
+
+func (b *circuitBreaker) middleware() Middleware {
+	return func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			group := b.cfg.Group(req.Path)
+			probe, err := b.allow(group)
+			if err != nil {
+				return nil, err
+			}
+			recorded := false
+			defer func() {
+				if !recorded {
+					// next panicked. Give back the trial slot, or the
+					// circuit would stay half-open with none left.
+					b.release(group, probe)
+				}
+			}()
+			resp, err := next(req)
+			recorded = true
+			b.record(group, probe, err, req.Context().Err() != nil)
+			return resp, err
+		}
+	}
+}
+
+// allow reports whether a call to group may proceed, and whether it is a
+// trial call of a half-open circuit.
+func (b *circuitBreaker) allow(group string) (probe bool, err error) {
+	b.mu.Lock()
+	c := b.circuit(group)
+	var t *circuitTransition
+	if c.state == CircuitOpen {
+		retryAt := c.openedAt.Add(b.cfg.CoolDown)
+		if b.now().Before(retryAt) {
+			b.mu.Unlock()
+			return false, &CircuitOpenError{Group: group, RetryAt: retryAt}
+		}
+		t = b.transition(group, c, CircuitHalfOpen)
+	}
+	if c.state == CircuitHalfOpen {
+		if c.probes >= b.cfg.HalfOpenRequests {
+			b.mu.Unlock()
+			return false, &CircuitOpenError{Group: group}
+		}
+		c.probes++
+		probe = true
+	}
+	b.mu.Unlock()
+	b.notify(t)
+	return probe, nil
+}
+
+// release gives back the trial slot of a call allowed by allow that ended
+// without an outcome.
+func (b *circuitBreaker) release(group string, probe bool) {
+	if !probe {
+		return
+	}
+	b.mu.Lock()
+	b.circuit(group).probes--
+	b.mu.Unlock()
+}
+
+// record counts the outcome of a call allowed by allow. callerDone reports
+// whether the call's context had ended.
+func (b *circuitBreaker) record(group string, probe bool, err error, callerDone bool) {
+	failure := err != nil && b.cfg.IsFailure(err)
+	// Calls the caller gave up on, or that were never sent, say nothing
+	// about the API.
+	ignore := err != nil && (callerDone || IsCanceled(err) || !reachedAPI(err))
+
+	b.mu.Lock()
+	c := b.circuit(group)
+	var t *circuitTransition
+	switch {
+	case probe:
+		c.probes--
+		if c.state != CircuitHalfOpen || ignore {
+			break
+		}
+		if failure {
+			c.failures++
+			t = b.open(group, c)
+		} else {
+			c.failures = 0
+			t = b.transition(group, c, CircuitClosed)
+		}
+	case c.state != CircuitClosed || ignore:
+		// Calls that started before the circuit opened are not counted.
+	case failure:
+		c.failures++
+		if c.failures >= b.cfg.FailureThreshold {
+			t = b.open(group, c)
+		}
+	default:
+		c.failures = 0
+	}
+	b.mu.Unlock()
+	b.notify(t)
+}
+
+func (b *circuitBreaker) circuit(group string) *circuit {
+	c := b.circuits[group]
+	if c == nil {
+		c = &circuit{}
+		b.circuits[group] = c
+	}
+	return c
+}
+
+func (b *circuitBreaker) open(group string, c *circuit) *circuitTransition {
+	c.openedAt = b.now()
+	return b.transition(group, c, CircuitOpen)
+}
+
+func (b *circuitBreaker) transition(group string, c *circuit, to CircuitState) *circuitTransition {
+	t := &circuitTransition{group: group, from: c.state, to: to}
+	c.state = to
+	return t
+}
+
+func (b *circuitBreaker) notify(t *circuitTransition) {
+	if t != nil && b.cfg.OnStateChange != nil {
+		b.cfg.OnStateChange(t.group, t.from, t.to)
+	}
+}
+
+func (b *circuitBreaker) snapshot() []CircuitStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make([]CircuitStatus, 0, len(b.circuits))
+	for group, c := range b.circuits {
+		out = append(out, CircuitStatus{Group: group, State: c.state, Failures: c.failures, OpenedAt: c.openedAt})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Group < out[j].Group })
+	return out
+}
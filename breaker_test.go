@@ -0,0 +1,255 @@
+package proxyhat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type transition struct {
+	group    string
+	from, to CircuitState
+}
+
+// setupBreakerTest returns a client whose circuit breaker runs on a fake
+// clock, and the state changes it reported.
+func setupBreakerTest(cfg CircuitBreakerConfig) (*Client, *http.ServeMux, *time.Time, *[]transition, func()) {
+	var changes []transition
+	cfg.OnStateChange = func(group string, from, to CircuitState) {
+		changes = append(changes, transition{group, from, to})
+	}
+	client, mux, cleanup := setupTest(WithCircuitBreaker(cfg))
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	client.breaker.now = func() time.Time { return now }
+	return client, mux, &now, &changes, cleanup
+}
+
+func TestCircuitBreaker_OpensAndRecovers(t *testing.T) {
+	client, mux, now, changes, cleanup := setupBreakerTest(CircuitBreakerConfig{FailureThreshold: 3, CoolDown: time.Minute})
+	defer cleanup()
+
+	var calls atomic.Int32
+	var healthy atomic.Bool
+	mux.HandleFunc("/sub-users", func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if !healthy.Load() {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"message": "down"})
+			return
+		}
+		writePayload(w, []SubUser{})
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.SubUsers.List(ctx); !errors.Is(err, ErrServer) {
+			t.Fatalf("call %d: err = %v", i, err)
+		}
+	}
+	_, err := client.SubUsers.List(ctx)
+	var coe *CircuitOpenError
+	if !errors.As(err, &coe) || !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err = %v, want ErrCircuitOpen", err)
+	}
+	if coe.Group != "sub-users" || !coe.RetryAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("CircuitOpenError = %+v", coe)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("calls = %d, want 3", calls.Load())
+	}
+	if !IsRetryable(err) {
+		t.Error("ErrCircuitOpen should be retryable")
+	}
+
+	// Other endpoint groups are unaffected.
+	mux.HandleFunc("/regular-options", func(w http.ResponseWriter, r *http.Request) {
+		writePayload(w, []RegularPlan{})
+	})
+	if _, err := client.Plans.ListRegular(ctx); err != nil {
+		t.Errorf("other group: %v", err)
+	}
+
+	// A failed trial call reopens the circuit.
+	*now = now.Add(time.Minute)
+	if _, err := client.SubUsers.List(ctx); !errors.Is(err, ErrServer) {
+		t.Fatalf("trial call err = %v", err)
+	}
+	if _, err := client.SubUsers.List(ctx); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("after failed trial err = %v", err)
+	}
+
+	// A successful one closes it.
+	*now = now.Add(time.Minute)
+	healthy.Store(true)
+	if _, err := client.SubUsers.List(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.SubUsers.List(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []transition{
+		{"sub-users", CircuitClosed, CircuitOpen},
+		{"sub-users", CircuitOpen, CircuitHalfOpen},
+		{"sub-users", CircuitHalfOpen, CircuitOpen},
+		{"sub-users", CircuitOpen, CircuitHalfOpen},
+		{"sub-users", CircuitHalfOpen, CircuitClosed},
+	}
+	if fmt.Sprint(*changes) != fmt.Sprint(want) {
+		t.Errorf("state changes = %v, want %v", *changes, want)
+	}
+}
+
+func TestCircuitBreaker_ClientErrorsDoNotCount(t *testing.T) {
+	client, mux, _, _, cleanup := setupBreakerTest(CircuitBreakerConfig{FailureThreshold: 2})
+	defer cleanup()
+	mux.HandleFunc("/sub-users/", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not found"})
+	})
+
+	for i := 0; i < 5; i++ {
+		if _, err := client.SubUsers.Get(context.Background(), "missing"); !IsNotFoundError(err) {
+			t.Fatalf("call %d: err = %v", i, err)
+		}
+	}
+}
+
+func TestCircuitBreaker_CallerDeadlinesDoNotCount(t *testing.T) {
+	client, mux, _, _, cleanup := setupBreakerTest(CircuitBreakerConfig{FailureThreshold: 1})
+	defer cleanup()
+	WithEndpointRateLimit("sub-users", 0.1, 1)(client)
+	var calls atomic.Int32
+	mux.HandleFunc("/sub-users", func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		writePayload(w, []SubUser{})
+	})
+	mux.HandleFunc("/sub-user-groups", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+
+	if _, err := client.SubUsers.List(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// The limiter refuses to wait past the deadline; nothing is sent.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := client.SubUsers.List(ctx); !errors.Is(err, ErrRateLimitWait) {
+		t.Fatalf("err = %v, want ErrRateLimitWait", err)
+	}
+	// The caller's deadline expires while the API is still working.
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.SubUserGroups.List(ctx); !IsTimeout(err) {
+		t.Fatalf("err = %v, want a timeout", err)
+	}
+
+	for _, c := range client.Health().Circuits {
+		if c.State != CircuitClosed || c.Failures != 0 {
+			t.Errorf("circuit %+v, want closed without failures", c)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("calls = %d, want 1", calls.Load())
+	}
+}
+
+func TestCircuitBreaker_HalfOpenLimit(t *testing.T) {
+	client, _, now, _, cleanup := setupBreakerTest(CircuitBreakerConfig{FailureThreshold: 1, CoolDown: time.Second})
+	defer cleanup()
+	b := client.breaker
+
+	b.record("g", false, &TransportError{Err: errors.New("connection refused")}, false)
+	*now = now.Add(time.Second)
+	probe, err := b.allow("g")
+	if !probe || err != nil {
+		t.Fatalf("allow = %v, %v, want a trial call", probe, err)
+	}
+	var coe *CircuitOpenError
+	if _, err := b.allow("g"); !errors.As(err, &coe) || !coe.RetryAt.IsZero() {
+		t.Fatalf("second allow err = %v, want open with a trial in flight", err)
+	}
+	// A canceled trial frees its slot without deciding the state.
+	b.record("g", true, context.Canceled, false)
+	if probe, err := b.allow("g"); !probe || err != nil {
+		t.Fatalf("allow after canceled trial = %v, %v", probe, err)
+	}
+}
+
+func TestClient_Health(t *testing.T) {
+	if h := NewClient("key").Health(); !h.Healthy() || len(h.Circuits) != 0 {
+		t.Errorf("Health() without breaker = %+v", h)
+	}
+
+	client, _, now, _, cleanup := setupBreakerTest(CircuitBreakerConfig{FailureThreshold: 1})
+	defer cleanup()
+	b := client.breaker
+	b.record("plans", false, nil, false)
+	b.record("sub-users", false, &Error{StatusCode: 502}, false)
+
+	h := client.Health()
+	want := []CircuitStatus{
+		{Group: "plans", State: CircuitClosed},
+		{Group: "sub-users", State: CircuitOpen, Failures: 1, OpenedAt: *now},
+	}
+	if h.Healthy() || fmt.Sprint(h.Circuits) != fmt.Sprint(want) {
+		t.Errorf("Health() = %+v, want circuits %+v", h, want)
+	}
+	if got := CircuitHalfOpen.String(); got != "half-open" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestCircuitBreaker_PanicReleasesTrialSlot(t *testing.T) {
+	var panicking atomic.Bool
+	client, mux, cleanup := setupTest(
+		WithCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, CoolDown: time.Minute, HalfOpenRequests: 1}),
+		WithMiddleware(func(next Handler) Handler {
+			return func(req *Request) (*Response, error) {
+				if panicking.Load() {
+					panic("boom")
+				}
+				return next(req)
+			}
+		}),
+	)
+	defer cleanup()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	client.breaker.now = func() time.Time { return now }
+
+	var healthy atomic.Bool
+	mux.HandleFunc("/sub-users", func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"message": "down"})
+			return
+		}
+		writePayload(w, []SubUser{})
+	})
+	ctx := context.Background()
+
+	if _, err := client.SubUsers.List(ctx); !errors.Is(err, ErrServer) {
+		t.Fatalf("err = %v", err)
+	}
+	now = now.Add(time.Minute)
+	panicking.Store(true)
+	func() {
+		defer func() {
+			if v := recover(); v != "boom" {
+				t.Errorf("recovered %v, want boom", v)
+			}
+		}()
+		client.SubUsers.List(ctx)
+	}()
+
+	// The panicked trial call did not use up the only trial slot.
+	panicking.Store(false)
+	healthy.Store(true)
+	if _, err := client.SubUsers.List(ctx); err != nil {
+		t.Fatalf("err = %v", err)
+	}
+	if h := client.Health(); !h.Healthy() {
+		t.Errorf("Health = %+v", h)
+	}
+}
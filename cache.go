@@ -0,0 +1,426 @@
+package proxyhat
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache stores API responses for WithCache. Implementations must be safe
+// for concurrent use. Caches are best effort: failures to read or write
+// an entry are treated as misses.
+type Cache interface {
+	Get(key string) (CacheEntry, bool)
+	Set(key string, entry CacheEntry)
+	// DeletePrefix removes every entry whose key starts with prefix.
+	DeletePrefix(prefix string)
+}
+
+// CacheEntry is a cached API response.
+type CacheEntry struct {
+	// Payload is the response payload, unwrapped from its envelope.
+	Payload      json.RawMessage `json:"payload"`
+	ETag         string          `json:"etag,omitempty"`
+	LastModified string          `json:"last_modified,omitempty"`
+	// StoredAt is when the payload was fetched or last revalidated.
+	StoredAt time.Time `json:"stored_at"`
+}
+
+// CachePolicy says how long responses of an endpoint are cached.
+type CachePolicy struct {
+	// TTL is how long an entry is served without contacting the API. Zero
+	// disables caching.
+	TTL time.Duration
+	// StaleWhileRevalidate is how long after TTL an entry is still served
+	// while it is revalidated in the background.
+	StaleWhileRevalidate time.Duration
+}
+
+// CacheRule applies Policy to the API paths matching Pattern, which uses
+// path.Match syntax without a leading slash.
+type CacheRule struct {
+	Pattern string
+	Policy  CachePolicy
+}
+
+var defaultCacheRules = []CacheRule{
+	{"locations/*", CachePolicy{TTL: 6 * time.Hour, StaleWhileRevalidate: time.Hour}},
+	{"regular-options", CachePolicy{TTL: time.Hour, StaleWhileRevalidate: time.Hour}},
+	{"subscription-plans", CachePolicy{TTL: time.Hour, StaleWhileRevalidate: time.Hour}},
+	{"plans/*/*", CachePolicy{TTL: time.Hour, StaleWhileRevalidate: time.Hour}},
+	{"pricing/*", CachePolicy{TTL: time.Hour, StaleWhileRevalidate: time.Hour}},
+	{"payments/cryptocurrencies", CachePolicy{TTL: 10 * time.Minute, StaleWhileRevalidate: 10 * time.Minute}},
+}
+
+// DefaultCachePolicies returns the rules for the read-only endpoints cached
+// by WithCache: locations, plans, pricing and cryptocurrencies. They apply
+// after any set with WithCachePolicy.
+func DefaultCachePolicies() []CacheRule {
+	return append([]CacheRule(nil), defaultCacheRules...)
+}
+
+// WithCache caches GET responses in cache according to WithCachePolicy and
+// DefaultCachePolicies. Expired entries are
+// revalidated with If-None-Match and If-Modified-Since. Entries within
+// their StaleWhileRevalidate window are returned at once and refreshed in
+// the background. Entries hold the payload as the API sent it, so any
+// Result type can be decoded from them. A panic during a background
+// refresh is logged to the WithLogger logger, or slog.Default().
+//
+// Any other call made through the client, such as SubUsers.Create,
+// invalidates the cached responses of its endpoint group, e.g. every
+// "sub-users" path. Entries are keyed by API key, so several clients may
+// share a cache.
+func WithCache(cache Cache) Option {
+	return func(c *Client) {
+		c.responseCache().store = cache
+	}
+}
+
+// WithCachePolicy sets the cache policy for API paths matching pattern,
+// which uses path.Match syntax without a leading slash. It overrides
+// DefaultCachePolicies; a zero policy turns caching off for the paths.
+// The first matching pattern wins.
+func WithCachePolicy(pattern string, p CachePolicy) Option {
+	return func(c *Client) {
+		rc := c.responseCache()
+		rc.rules = append(rc.rules, CacheRule{Pattern: strings.Trim(pattern, "/"), Policy: p})
+	}
+}
+
+func (c *Client) responseCache() *responseCache {
+	if c.cache == nil {
+		c.cache = &responseCache{now: time.Now}
+	}
+	return c.cache
+}
+
+type responseCache struct {
+	store     Cache
+	rules     []CacheRule
+	namespace string
+	now       func() time.Time
+
+	// mu orders invalidations against stores. generations counts the
+	// invalidations of each endpoint group, so that a response fetched
+	// before one is not stored after it.
+	mu          sync.Mutex
+	generations map[string]uint64
+
+	// revalidating holds the keys being refreshed in the background.
+	revalidating sync.Map
+	wg           sync.WaitGroup
+	// logger receives panics from background revalidation. It is the
+	// client's logger, or slog.Default() if none is set.
+	logger *slog.Logger
+}
+
+// cacheNamespace separates the entries of different accounts and API
+// servers sharing a cache, without storing the API key.
+func cacheNamespace(baseURL, apiKey string) string {
+	sum := sha256.Sum256([]byte(baseURL + "\x00" + apiKey))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (rc *responseCache) policy(apiPath string) CachePolicy {
+	apiPath = strings.Trim(apiPath, "/")
+	for _, rules := range [][]CacheRule{rc.rules, defaultCacheRules} {
+		for _, r := range rules {
+			if ok, _ := path.Match(r.Pattern, apiPath); ok {
+				return r.Policy
+			}
+		}
+	}
+	return CachePolicy{}
+}
+
+func (rc *responseCache) key(req *Request) string {
+	return rc.namespace + " " + strings.Trim(req.Path, "/") + "?" + req.Params.Encode()
+}
+
+func cacheGroup(apiPath string) string {
+	group, _, _ := strings.Cut(strings.Trim(apiPath, "/"), "/")
+	return group
+}
+
+// generation returns the invalidation count of apiPath's endpoint group.
+func (rc *responseCache) generation(apiPath string) uint64 {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.generations[cacheGroup(apiPath)]
+}
+
+// invalidate removes the entries of apiPath's endpoint group.
+func (rc *responseCache) invalidate(apiPath string) {
+	group := cacheGroup(apiPath)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.generations == nil {
+		rc.generations = map[string]uint64{}
+	}
+	rc.generations[group]++
+	rc.store.DeletePrefix(rc.namespace + " " + group + "?")
+	rc.store.DeletePrefix(rc.namespace + " " + group + "/")
+}
+
+// set stores entry unless apiPath's group was invalidated since gen.
+func (rc *responseCache) set(key, apiPath string, gen uint64, entry CacheEntry) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.generations[cacheGroup(apiPath)] == gen {
+		rc.store.Set(key, entry)
+	}
+}
+
+func (rc *responseCache) middleware() Middleware {
+	return func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			switch req.Method {
+			case http.MethodGet:
+			case http.MethodHead, http.MethodOptions:
+				return next(req)
+			default:
+				resp, err := next(req)
+				rc.invalidate(req.Path)
+				return resp, err
+			}
+
+			policy := rc.policy(req.Path)
+			if policy.TTL <= 0 || req.raw || req.Result == nil {
+				return next(req)
+			}
+			key := rc.key(req)
+			entry, found := rc.store.Get(key)
+			if !found {
+				return rc.fetch(key, req, nil, next)
+			}
+			age := rc.now().Sub(entry.StoredAt)
+			switch {
+			case age < policy.TTL:
+			case age < policy.TTL+policy.StaleWhileRevalidate:
+				rc.revalidate(key, req, entry, next)
+			default:
+				return rc.fetch(key, req, &entry, next)
+			}
+			return rc.serve(req, entry, age)
+		}
+	}
+}
+
+// fetch performs req, conditionally if a previous entry exists, and
+// stores the result.
+func (rc *responseCache) fetch(key string, req *Request, prev *CacheEntry, next Handler) (*Response, error) {
+	inner := req.WithContext(req.Context())
+	inner.Result = newResult(req.Result)
+	if prev != nil && (prev.ETag != "" || prev.LastModified != "") {
+		inner.Header = req.Header.Clone()
+		if inner.Header == nil {
+			inner.Header = http.Header{}
+		}
+		if prev.ETag != "" {
+			inner.Header.Set("If-None-Match", prev.ETag)
+		}
+		if prev.LastModified != "" {
+			inner.Header.Set("If-Modified-Since", prev.LastModified)
+		}
+	}
+
+	gen := rc.generation(req.Path)
+	resp, err := next(inner)
+	if err != nil {
+		return resp, err
+	}
+	if resp == nil || resp.Response == nil {
+		// Middleware answered without a response, so there is nothing to
+		// revalidate with later; pass the result on without storing it.
+		payload, err := responsePayload(resp, inner.Result)
+		if err != nil {
+			return resp, err
+		}
+		return resp, decodeCached(CacheEntry{Payload: payload}, req.Result)
+	}
+	entry := CacheEntry{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		StoredAt:     rc.now(),
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		if prev == nil {
+			return resp, nil
+		}
+		entry.Payload = prev.Payload
+		if entry.ETag == "" {
+			entry.ETag = prev.ETag
+		}
+		if entry.LastModified == "" {
+			entry.LastModified = prev.LastModified
+		}
+	} else {
+		payload, err := responsePayload(resp, inner.Result)
+		if err != nil {
+			return resp, err
+		}
+		entry.Payload = payload
+	}
+	if !strings.Contains(resp.Header.Get("Cache-Control"), "no-store") {
+		rc.set(key, req.Path, gen, entry)
+	}
+	if err := decodeCached(entry, req.Result); err != nil {
+		return resp, err
+	}
+	return resp, nil
+}
+
+// revalidate refreshes entry in the background unless that is already
+// under way.
+func (rc *responseCache) revalidate(key string, req *Request, entry CacheEntry, next Handler) {
+	if _, busy := rc.revalidating.LoadOrStore(key, true); busy {
+		return
+	}
+	bg := req.WithContext(context.WithoutCancel(req.Context()))
+	bg.Result = newResult(req.Result)
+	rc.wg.Add(1)
+	go func() {
+		defer rc.wg.Done()
+		defer rc.revalidating.Delete(key)
+		// Nobody waits for the refresh, so a panic in next is logged
+		// rather than crashing the process.
+		defer func() {
+			if v := recover(); v != nil {
+				rc.logPanic(bg, v)
+			}
+		}()
+		rc.fetch(key, bg, &entry, next)
+	}()
+}
+
+// logPanic reports a panic recovered from the background revalidation of
+// req.
+func (rc *responseCache) logPanic(req *Request, v any) {
+	l := rc.logger
+	if l == nil {
+		l = slog.Default()
+	}
+	l.LogAttrs(req.Context(), slog.LevelError, "proxyhat cache revalidation panicked",
+		slog.String("method", req.Method),
+		slog.String("path", req.Path),
+		slog.String("panic", fmt.Sprint(v)),
+		slog.String("stack", string(debug.Stack())),
+	)
+}
+
+func (rc *responseCache) serve(req *Request, entry CacheEntry, age time.Duration) (*Response, error) {
+	if err := decodeCached(entry, req.Result); err != nil {
+		return nil, err
+	}
+	h := http.Header{}
+	if entry.ETag != "" {
+		h.Set("ETag", entry.ETag)
+	}
+	if entry.LastModified != "" {
+		h.Set("Last-Modified", entry.LastModified)
+	}
+	h.Set("Age", strconv.Itoa(int(age/time.Second)))
+	return &Response{
+		Response: &http.Response{
+			Status:     "200 OK",
+			StatusCode: http.StatusOK,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     h,
+			Body:       http.NoBody,
+		},
+		Cached: true,
+		size:   int64(len(entry.Payload)),
+	}, nil
+}
+
+func decodeCached(entry CacheEntry, result any) error {
+	if err := json.Unmarshal(entry.Payload, result); err != nil {
+		return &DecodeError{StatusCode: http.StatusOK, Body: entry.Payload, Err: err}
+	}
+	return nil
+}
+
+// LRUCache is an in-memory Cache that evicts the least recently used
+// entries beyond its capacity.
+type LRUCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // of *lruItem, most recently used first
+	items    map[string]*list.Element
+}
+
+type lruItem struct {
+	key   string
+	entry CacheEntry
+}
+
+// NewLRUCache creates an LRUCache holding up to capacity entries, or 1000
+// if capacity is not positive.
+func NewLRUCache(capacity int) *LRUCache {
+	if capacity <= 0 {
+		capacity = 1000
+	}
+	return &LRUCache{capacity: capacity, order: list.New(), items: map[string]*list.Element{}}
+}
+
+// Get implements Cache.
+func (c *LRUCache) Get(key string) (CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return CacheEntry{}, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*lruItem).entry, true
+}
+
+// Set implements Cache.
+func (c *LRUCache) Set(key string, entry CacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		el.Value.(*lruItem).entry = entry
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruItem{key: key, entry: entry})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruItem).key)
+	}
+}
+
+// DeletePrefix implements Cache.
+func (c *LRUCache) DeletePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, el := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.order.Remove(el)
+			delete(c.items, key)
+		}
+	}
+}
+
+// Len returns the number of entries.
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
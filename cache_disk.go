@@ -0,0 +1,118 @@
+package proxyhat
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DiskCache is a Cache that keeps one JSON file per entry in a directory,
+// so cached responses survive restarts and can be shared by processes.
+//
+// DeletePrefix works from an in-memory index of the keys in the directory,
+// read when it is first needed and kept up to date by Set. Entries another
+// process stores after that are not removed by it, and expire by TTL
+// instead.
+type DiskCache struct {
+	dir string
+
+	mu sync.Mutex
+	// keys maps the key of each known entry to its file.
+	keys map[string]string
+}
+
+type diskCacheFile struct {
+	Key   string     `json:"key"`
+	Entry CacheEntry `json:"entry"`
+}
+
+// NewDiskCache creates a DiskCache in dir, creating the directory if
+// needed.
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &DiskCache{dir: dir}, nil
+}
+
+func (c *DiskCache) file(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (c *DiskCache) read(name string) (diskCacheFile, bool) {
+	var f diskCacheFile
+	data, err := os.ReadFile(name)
+	if err != nil || json.Unmarshal(data, &f) != nil {
+		return f, false
+	}
+	return f, true
+}
+
+// Get implements Cache.
+func (c *DiskCache) Get(key string) (CacheEntry, bool) {
+	f, ok := c.read(c.file(key))
+	if !ok || f.Key != key {
+		return CacheEntry{}, false
+	}
+	return f.Entry, true
+}
+
+// Set implements Cache. The file is replaced atomically, so concurrent
+// readers never see a partial entry.
+func (c *DiskCache) Set(key string, entry CacheEntry) {
+	data, err := json.Marshal(diskCacheFile{Key: key, Entry: entry})
+	if err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	name := c.file(key)
+	if err == nil {
+		err = os.Rename(tmp.Name(), name)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	c.index()[key] = name
+}
+
+// DeletePrefix implements Cache.
+func (c *DiskCache) DeletePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, name := range c.index() {
+		if strings.HasPrefix(key, prefix) {
+			os.Remove(name)
+			delete(c.keys, key)
+		}
+	}
+}
+
+// index returns c.keys, reading the directory on first use. c.mu must be
+// held.
+func (c *DiskCache) index() map[string]string {
+	if c.keys == nil {
+		c.keys = map[string]string{}
+		names, _ := filepath.Glob(filepath.Join(c.dir, "*.json"))
+		for _, name := range names {
+			if f, ok := c.read(name); ok {
+				c.keys[f.Key] = name
+			}
+		}
+	}
+	return c.keys
+}
@@ -0,0 +1,51 @@
+package proxyhat
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiskCache(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	c, err := NewDiskCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.Set("ns locations/countries?", CacheEntry{Payload: []byte(`[{"code":"US"}]`), ETag: `"v1"`, StoredAt: stored})
+	c.Set("ns sub-users?", CacheEntry{Payload: []byte(`[]`)})
+	c.Set("ns sub-users/1?", CacheEntry{Payload: []byte(`{}`)})
+
+	// A second instance sees the same entries.
+	c2, err := NewDiskCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, ok := c2.Get("ns locations/countries?")
+	if !ok || string(e.Payload) != `[{"code":"US"}]` || e.ETag != `"v1"` || !e.StoredAt.Equal(stored) {
+		t.Errorf("Get = %+v, %v", e, ok)
+	}
+	if _, ok := c2.Get("ns missing?"); ok {
+		t.Error("Get(missing) succeeded")
+	}
+
+	c2.DeletePrefix("ns sub-users")
+	if _, ok := c.Get("ns sub-users/1?"); ok {
+		t.Error("entry survived DeletePrefix")
+	}
+	if _, ok := c.Get("ns locations/countries?"); !ok {
+		t.Error("DeletePrefix removed an unrelated entry")
+	}
+	// Entries stored after the index was read are found too.
+	c2.Set("ns plans?", CacheEntry{Payload: []byte(`[]`)})
+	c2.DeletePrefix("ns plans")
+	if _, ok := c.Get("ns plans?"); ok {
+		t.Error("entry stored after indexing survived DeletePrefix")
+	}
+	names, _ := os.ReadDir(dir)
+	if len(names) != 1 {
+		t.Errorf("%d files left, want 1", len(names))
+	}
+}
@@ -0,0 +1,413 @@
+package proxyhat
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// setupCacheTest returns a client caching in an LRUCache on a fake clock.
+func setupCacheTest(opts ...Option) (*Client, *http.ServeMux, *time.Time, func()) {
+	client, mux, cleanup := setupTest(append([]Option{WithCache(NewLRUCache(0))}, opts...)...)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	client.cache.now = func() time.Time { return now }
+	return client, mux, &now, cleanup
+}
+
+func TestCache_FreshHit(t *testing.T) {
+	client, mux, _, cleanup := setupCacheTest()
+	defer cleanup()
+
+	var calls atomic.Int32
+	mux.HandleFunc("/locations/countries", func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		writeData(w, []Country{{Code: r.URL.Query().Get("name")}})
+	})
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		var resp Response
+		countries, err := client.Locations.Countries(ContextWithResponse(ctx, &resp), &LocationParams{Name: String("US")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(countries) != 1 || countries[0].Code != "US" {
+			t.Errorf("countries = %v", countries)
+		}
+		if resp.Cached != (i > 0) {
+			t.Errorf("call %d: Cached = %v", i, resp.Cached)
+		}
+	}
+	// Different query parameters are cached separately.
+	if _, err := client.Locations.Countries(ctx, &LocationParams{Name: String("DE")}); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("calls = %d, want 2", calls.Load())
+	}
+}
+
+func TestCache_Revalidation(t *testing.T) {
+	client, mux, now, cleanup := setupCacheTest(WithCachePolicy("locations/countries", CachePolicy{TTL: time.Minute}))
+	defer cleanup()
+
+	var calls atomic.Int32
+	var conditional atomic.Value
+	mux.HandleFunc("/locations/countries", func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		conditional.Store(r.Header.Get("If-None-Match"))
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		writeData(w, []Country{{Code: "US"}})
+	})
+
+	ctx := context.Background()
+	if _, err := client.Locations.Countries(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	*now = now.Add(2 * time.Minute)
+	var resp Response
+	countries, err := client.Locations.Countries(ContextWithResponse(ctx, &resp), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 2 || conditional.Load() != `"v1"` || resp.StatusCode != http.StatusNotModified {
+		t.Errorf("calls = %d, If-None-Match = %v, status = %d", calls.Load(), conditional.Load(), resp.StatusCode)
+	}
+	if len(countries) != 1 || countries[0].Code != "US" {
+		t.Errorf("countries = %v", countries)
+	}
+
+	// The 304 refreshed the entry.
+	*now = now.Add(30 * time.Second)
+	if _, err := client.Locations.Countries(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("calls = %d, want 2", calls.Load())
+	}
+}
+
+func TestCache_StaleWhileRevalidate(t *testing.T) {
+	client, mux, now, cleanup := setupCacheTest(WithCachePolicy("pricing/*", CachePolicy{TTL: time.Minute, StaleWhileRevalidate: time.Hour}))
+	defer cleanup()
+
+	var version atomic.Int32
+	mux.HandleFunc("/pricing/regular", func(w http.ResponseWriter, r *http.Request) {
+		writePayload(w, map[string]int32{"version": version.Add(1)})
+	})
+
+	ctx := context.Background()
+	get := func() int32 {
+		t.Helper()
+		var result map[string]int32
+		if err := client.doRequest(ctx, "", "GET", "pricing/regular", nil, &result); err != nil {
+			t.Fatal(err)
+		}
+		return result["version"]
+	}
+
+	if v := get(); v != 1 {
+		t.Fatalf("version = %d, want 1", v)
+	}
+	*now = now.Add(10 * time.Minute)
+	if v := get(); v != 1 {
+		t.Errorf("stale read version = %d, want 1", v)
+	}
+	client.cache.wg.Wait()
+	if v := get(); v != 2 {
+		t.Errorf("after revalidation version = %d, want 2", v)
+	}
+
+	// Beyond the stale window the call waits for a fresh response.
+	*now = now.Add(2 * time.Hour)
+	if v := get(); v != 3 {
+		t.Errorf("expired read version = %d, want 3", v)
+	}
+}
+
+func TestCache_InvalidatedByMutation(t *testing.T) {
+	client, mux, _, cleanup := setupCacheTest(WithCachePolicy("sub-users*", CachePolicy{TTL: time.Hour}))
+	defer cleanup()
+
+	var lists atomic.Int32
+	mux.HandleFunc("/sub-users", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			lists.Add(1)
+			writePayload(w, []SubUser{})
+			return
+		}
+		writePayload(w, SubUser{UUID: "su-1"})
+	})
+	mux.HandleFunc("/sub-user-groups", func(w http.ResponseWriter, r *http.Request) {
+		writePayload(w, SubUserGroup{})
+	})
+
+	ctx := context.Background()
+	client.SubUsers.List(ctx)
+	client.SubUsers.List(ctx)
+	// A mutation in another group leaves the entry alone.
+	if _, err := client.SubUserGroups.Create(ctx, CreateSubUserGroupParams{}); err != nil {
+		t.Fatal(err)
+	}
+	client.SubUsers.List(ctx)
+	if lists.Load() != 1 {
+		t.Errorf("lists = %d, want 1", lists.Load())
+	}
+	if _, err := client.SubUsers.Create(ctx, CreateSubUserParams{}); err != nil {
+		t.Fatal(err)
+	}
+	client.SubUsers.List(ctx)
+	if lists.Load() != 2 {
+		t.Errorf("lists = %d, want 2 after Create", lists.Load())
+	}
+}
+
+func TestCache_MutationDuringFetch(t *testing.T) {
+	client, mux, _, cleanup := setupCacheTest(WithCachePolicy("sub-users", CachePolicy{TTL: time.Hour}))
+	defer cleanup()
+
+	var lists atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	mux.HandleFunc("/sub-users", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writePayload(w, SubUser{})
+			return
+		}
+		if lists.Add(1) == 1 {
+			close(started)
+			<-release
+		}
+		writePayload(w, []SubUser{})
+	})
+
+	ctx := context.Background()
+	done := make(chan error)
+	go func() {
+		_, err := client.SubUsers.List(ctx)
+		done <- err
+	}()
+	<-started
+	if _, err := client.SubUsers.Create(ctx, CreateSubUserParams{}); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// The list fetched before the mutation was not stored.
+	client.SubUsers.List(ctx)
+	client.SubUsers.List(ctx)
+	if lists.Load() != 2 {
+		t.Errorf("lists = %d, want 2", lists.Load())
+	}
+}
+
+func TestCache_PolicyOrder(t *testing.T) {
+	client, _, _, cleanup := setupCacheTest(
+		WithCachePolicy("locations/cities", CachePolicy{}),
+		WithCachePolicy("locations/*", CachePolicy{TTL: time.Minute}),
+	)
+	defer cleanup()
+
+	rc := client.cache
+	for i := 0; i < 20; i++ {
+		if p := rc.policy("locations/cities"); p.TTL != 0 {
+			t.Fatalf("locations/cities policy = %+v, want the first matching rule", p)
+		}
+		if p := rc.policy("locations/countries"); p.TTL != time.Minute {
+			t.Fatalf("locations/countries policy = %+v", p)
+		}
+		if p := rc.policy("pricing/regular"); p.TTL != time.Hour {
+			t.Fatalf("pricing/regular policy = %+v, want the default", p)
+		}
+	}
+	rules := DefaultCachePolicies()
+	rules[0].Policy.TTL = 0
+	if DefaultCachePolicies()[0].Policy.TTL == 0 {
+		t.Error("DefaultCachePolicies returned the shared rules")
+	}
+}
+
+func TestCache_UncachedEndpointsAndAccounts(t *testing.T) {
+	cache := NewLRUCache(0)
+	client, mux, cleanup := setupTest(WithCache(cache))
+	defer cleanup()
+
+	var users, countries atomic.Int32
+	mux.HandleFunc("/sub-users", func(w http.ResponseWriter, r *http.Request) {
+		users.Add(1)
+		writePayload(w, []SubUser{})
+	})
+	mux.HandleFunc("/locations/countries", func(w http.ResponseWriter, r *http.Request) {
+		countries.Add(1)
+		writeData(w, []Country{})
+	})
+
+	ctx := context.Background()
+	client.SubUsers.List(ctx)
+	client.SubUsers.List(ctx)
+	if users.Load() != 2 {
+		t.Errorf("sub-users calls = %d, want 2", users.Load())
+	}
+
+	other := NewClient("other-key", WithBaseURL(client.baseURL), WithCache(cache))
+	client.Locations.Countries(ctx, nil)
+	other.Locations.Countries(ctx, nil)
+	client.Locations.Countries(ctx, nil)
+	if countries.Load() != 2 {
+		t.Errorf("countries calls = %d, want one per API key", countries.Load())
+	}
+}
+
+func TestCache_NoStore(t *testing.T) {
+	client, mux, _, cleanup := setupCacheTest()
+	defer cleanup()
+
+	var calls atomic.Int32
+	mux.HandleFunc("/locations/isps", func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Cache-Control", "no-store")
+		writeData(w, []ISP{})
+	})
+
+	client.Locations.ISPs(context.Background(), nil)
+	client.Locations.ISPs(context.Background(), nil)
+	if calls.Load() != 2 {
+		t.Errorf("calls = %d, want 2", calls.Load())
+	}
+}
+
+func TestCache_CannedResponse(t *testing.T) {
+	var panicking atomic.Bool
+	var logs bytes.Buffer
+	client, _, now, cleanup := setupCacheTest(
+		WithLogger(slog.New(slog.NewJSONHandler(&logs, nil))),
+		WithCachePolicy("pricing/*", CachePolicy{TTL: time.Minute, StaleWhileRevalidate: time.Hour}),
+		WithMiddleware(func(next Handler) Handler {
+			return func(req *Request) (*Response, error) {
+				if panicking.Load() {
+					panic("boom")
+				}
+				*req.Result.(*[]Country) = []Country{{Code: "XX"}}
+				return &Response{Response: &http.Response{StatusCode: http.StatusOK}}, nil
+			}
+		}),
+	)
+	defer cleanup()
+
+	ctx := context.Background()
+	get := func() []Country {
+		t.Helper()
+		var result []Country
+		if err := client.doRequest(ctx, "", "GET", "pricing/regular", nil, &result); err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	for i := 0; i < 2; i++ {
+		if got := get(); len(got) != 1 || got[0].Code != "XX" {
+			t.Fatalf("call %d: %+v", i, got)
+		}
+	}
+
+	// A panic during background revalidation is logged rather than
+	// crashing the process.
+	panicking.Store(true)
+	*now = now.Add(10 * time.Minute)
+	if got := get(); len(got) != 1 || got[0].Code != "XX" {
+		t.Errorf("stale read: %+v", got)
+	}
+	client.cache.wg.Wait()
+	if !strings.Contains(logs.String(), `"msg":"proxyhat cache revalidation panicked"`) || !strings.Contains(logs.String(), `"panic":"boom"`) {
+		t.Errorf("logs = %s", logs.String())
+	}
+}
+
+func TestCache_NilResponseFromMiddleware(t *testing.T) {
+	var calls atomic.Int32
+	client, _, _, cleanup := setupCacheTest(WithMiddleware(func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			calls.Add(1)
+			*req.Result.(*[]Country) = []Country{{Code: "XX"}}
+			return nil, nil
+		}
+	}))
+	defer cleanup()
+
+	for i := 0; i < 2; i++ {
+		countries, err := client.Locations.Countries(context.Background(), nil)
+		if err != nil || len(countries) != 1 || countries[0].Code != "XX" {
+			t.Fatalf("call %d: %+v, %v", i, countries, err)
+		}
+	}
+	// Without a response there is nothing to revalidate with, so the
+	// result is not stored.
+	if calls.Load() != 2 {
+		t.Errorf("calls = %d, want 2", calls.Load())
+	}
+}
+
+func TestCache_StoresAPIPayload(t *testing.T) {
+	client, mux, _, cleanup := setupCacheTest()
+	defer cleanup()
+
+	var calls atomic.Int32
+	mux.HandleFunc("/locations/countries", func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		writeData(w, []map[string]any{{"code": "US", "cities": 42}})
+	})
+
+	ctx := context.Background()
+	if _, err := client.Locations.Countries(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	req, err := client.NewRequest(ctx, http.MethodGet, "locations/countries", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []map[string]any
+	if _, err := client.Do(req, &got); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("calls = %d, want 1", calls.Load())
+	}
+	// The cached entry holds what the API sent, not the Country shape of
+	// the call that stored it.
+	want := map[string]any{"code": "US", "cities": float64(42)}
+	if len(got) != 1 || !reflect.DeepEqual(got[0], want) {
+		t.Errorf("got %v, want [%v]", got, want)
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	c := NewLRUCache(2)
+	c.Set("a", CacheEntry{Payload: []byte(`1`)})
+	c.Set("b", CacheEntry{Payload: []byte(`2`)})
+	c.Get("a")
+	c.Set("c", CacheEntry{Payload: []byte(`3`)})
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("least recently used entry was not evicted")
+	}
+	if e, ok := c.Get("a"); !ok || string(e.Payload) != "1" {
+		t.Errorf("Get(a) = %s, %v", e.Payload, ok)
+	}
+	c.DeletePrefix("c")
+	if c.Len() != 1 {
+		t.Errorf("Len() = %d after DeletePrefix, want 1", c.Len())
+	}
+}
@@ -0,0 +1,152 @@
+// Command proxyhat-forward runs a local HTTP/HTTPS proxy that forwards every
+// connection to the ProxyHat gateway with sub-user credentials and targeting
+// injected, for tools that cannot send proxy credentials themselves.
+//
+// Usage:
+//
+//	PROXYHAT_PROXY_PASSWORD=secret proxyhat-forward -username user1 -country US
+//	curl -x http://127.0.0.1:8888 https://example.com
+//
+// With -tenants, each local client authenticates with its own credentials
+// and is mapped to a sub-user from the JSON configuration file. The file is
+// reloaded on SIGHUP, when it changes, or on POST /reload to the -admin
+// address, which also serves per-tenant counters on GET /stats.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	proxyhat "github.com/ProxyHatCom/go-sdk"
+	"github.com/ProxyHatCom/go-sdk/internal/cliflag"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("proxyhat-forward", flag.ContinueOnError)
+	listen := fs.String("listen", "127.0.0.1:8888", "local address to listen on")
+	username := fs.String("username", os.Getenv("PROXYHAT_PROXY_USERNAME"), "sub-user proxy username")
+	password := fs.String("password", os.Getenv("PROXYHAT_PROXY_PASSWORD"), "sub-user proxy password")
+	gateway := fs.String("gateway", "", "gateway host:port (default "+proxyhat.DefaultGatewayHost+":"+strconv.Itoa(proxyhat.DefaultGatewayHTTPPort)+")")
+	localUser := fs.String("local-user", "", "require this username from local clients")
+	localPass := fs.String("local-pass", "", "require this password from local clients")
+	tenantsPath := fs.String("tenants", "", "multi-tenant JSON configuration file")
+	adminAddr := fs.String("admin", "", "address for the tenant admin endpoints (e.g. 127.0.0.1:8889)")
+	rulesPath := fs.String("rules", "", "JSON routing rules picking targeting per destination host")
+	reloadInterval := fs.Duration("reload-interval", 10*time.Second, "how often to check the tenants file for changes")
+
+	var t proxyhat.Targeting
+	fs.StringVar(&t.Country, "country", "", "country code")
+	fs.StringVar(&t.Region, "region", "", "region code")
+	fs.StringVar(&t.City, "city", "", "city code")
+	fs.StringVar(&t.ISP, "isp", "", "ISP code")
+	fs.StringVar(&t.Zipcode, "zip", "", "zipcode")
+	fs.StringVar(&t.ConnectionType, "type", "", "connection type")
+	fs.StringVar(&t.Session, "session", "", "sticky session ID")
+	fs.DurationVar(&t.SessionTTL, "session-ttl", 0, "sticky session lifetime")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var upstream []proxyhat.GatewayOption
+	if *gateway != "" {
+		gw, err := cliflag.ParseGateway(*gateway)
+		if err != nil {
+			return err
+		}
+		upstream = append(upstream, proxyhat.WithGateway(gw))
+	}
+	if *rulesPath != "" {
+		router, err := proxyhat.LoadRouter(*rulesPath)
+		if err != nil {
+			return err
+		}
+		upstream = append(upstream, proxyhat.WithRouter(router))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var handler http.Handler
+	if *tenantsPath != "" {
+		tenants, err := proxyhat.LoadTenantRegistry(*tenantsPath, upstream...)
+		if err != nil {
+			return err
+		}
+		handler = proxyhat.NewTenantForwarder(tenants)
+		go tenants.Watch(ctx, *reloadInterval, func(err error) {
+			log.Printf("reload %s: %v", *tenantsPath, err)
+		})
+		go reloadOnHangup(ctx, tenants)
+		if *adminAddr != "" {
+			go func() {
+				log.Printf("admin endpoints listening on %s", *adminAddr)
+				if err := http.ListenAndServe(*adminAddr, tenants.AdminHandler()); err != nil {
+					log.Printf("admin server: %v", err)
+				}
+			}()
+		}
+	} else {
+		if *username == "" || *password == "" {
+			return errors.New("-username and -password (or PROXYHAT_PROXY_USERNAME and PROXYHAT_PROXY_PASSWORD) are required")
+		}
+		opts := []proxyhat.ForwarderOption{proxyhat.WithUpstream(upstream...)}
+		if *localUser != "" || *localPass != "" {
+			opts = append(opts, proxyhat.WithLocalAuth(*localUser, *localPass))
+		}
+		forwarder, err := proxyhat.NewForwarder(&proxyhat.SubUser{ProxyUsername: *username}, *password, t, opts...)
+		if err != nil {
+			return err
+		}
+		handler = forwarder
+	}
+
+	srv := &http.Server{
+		Addr:              *listen,
+		Handler:           handler,
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("proxyhat-forward listening on %s", *listen)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func reloadOnHangup(ctx context.Context, tenants *proxyhat.TenantRegistry) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			if err := tenants.Reload(); err != nil {
+				log.Printf("reload: %v", err)
+			} else {
+				log.Printf("tenants reloaded")
+			}
+		}
+	}
+}
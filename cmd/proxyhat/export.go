@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	proxyhat "github.com/ProxyHatCom/go-sdk"
+	"github.com/ProxyHatCom/go-sdk/internal/cliflag"
+)
+
+func runExport(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("proxyhat export", flag.ContinueOnError)
+	apiKey := fs.String("api-key", os.Getenv("PROXYHAT_API_KEY"), "ProxyHat API key")
+	baseURL := fs.String("base-url", proxyhat.DefaultBaseURL, "API base URL")
+	format := fs.String("format", string(proxyhat.ExportHostPortUserPass), "output format: "+formatNames())
+	password := fs.String("password", os.Getenv("PROXYHAT_PROXY_PASSWORD"), "proxy password shared by all sub-users")
+	passwordsPath := fs.String("passwords", "", `JSON file mapping proxy usernames to passwords, e.g. {"user1": "pass"}`)
+	only := fs.String("sub-users", "", "comma-separated proxy usernames to export (default all)")
+	gateway := fs.String("gateway", "", "gateway host:port")
+	var variants targetingList
+	fs.Var(&variants, "targeting", "targeting variant such as country=US,city=new_york (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	exportFormat, err := proxyhat.ParseExportFormat(*format)
+	if err != nil {
+		return err
+	}
+	if *apiKey == "" {
+		return errors.New("-api-key or PROXYHAT_API_KEY is required")
+	}
+	passwords := map[string]string{}
+	if *passwordsPath != "" {
+		data, err := os.ReadFile(*passwordsPath)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &passwords); err != nil {
+			return fmt.Errorf("invalid -passwords file: %w", err)
+		}
+	} else if *password == "" {
+		return errors.New("-password, PROXYHAT_PROXY_PASSWORD or -passwords is required")
+	}
+	gw := proxyhat.DefaultGateway()
+	if *gateway != "" {
+		if gw, err = cliflag.ParseGateway(*gateway); err != nil {
+			return err
+		}
+	}
+
+	client := proxyhat.NewClient(*apiKey, proxyhat.WithBaseURL(*baseURL))
+	subUsers, err := client.SubUsers.List(context.Background())
+	if err != nil {
+		return err
+	}
+	subUsers = filterSubUsers(subUsers, *only)
+
+	list, err := gw.ProxyList(subUsers, func(su *proxyhat.SubUser) string {
+		if p, ok := passwords[su.ProxyUsername]; ok {
+			return p
+		}
+		return *password
+	}, variants...)
+	if err != nil {
+		return err
+	}
+	return list.Write(stdout, exportFormat)
+}
+
+func filterSubUsers(subUsers []proxyhat.SubUser, only string) []proxyhat.SubUser {
+	if only == "" {
+		return subUsers
+	}
+	want := map[string]bool{}
+	for _, name := range strings.Split(only, ",") {
+		want[strings.TrimSpace(name)] = true
+	}
+	var out []proxyhat.SubUser
+	for _, su := range subUsers {
+		if want[su.ProxyUsername] {
+			out = append(out, su)
+		}
+	}
+	return out
+}
+
+func formatNames() string {
+	names := make([]string, len(proxyhat.ExportFormats))
+	for i, f := range proxyhat.ExportFormats {
+		names[i] = string(f)
+	}
+	return strings.Join(names, ", ")
+}
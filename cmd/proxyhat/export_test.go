@@ -0,0 +1,34 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	proxyhat "github.com/ProxyHatCom/go-sdk"
+)
+
+func TestFilterSubUsers(t *testing.T) {
+	subUsers := []proxyhat.SubUser{
+		{ProxyUsername: "user1"},
+		{ProxyUsername: "user2"},
+		{ProxyUsername: "user3"},
+	}
+	tests := []struct {
+		only string
+		want []string
+	}{
+		{"", []string{"user1", "user2", "user3"}},
+		{"user2", []string{"user2"}},
+		{"user3, user1", []string{"user1", "user3"}},
+		{"nobody", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, su := range filterSubUsers(subUsers, tt.only) {
+			got = append(got, su.ProxyUsername)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("filterSubUsers(%q) = %v, want %v", tt.only, got, tt.want)
+		}
+	}
+}
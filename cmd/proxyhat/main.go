@@ -0,0 +1,95 @@
+// Command proxyhat is a command-line companion to the ProxyHat Go SDK.
+//
+// Usage:
+//
+//	proxyhat export -format curl -password proxy-pass -targeting country=US
+//	proxyhat probe -username user1 -password proxy-pass -targeting country=US -targeting country=DE
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	proxyhat "github.com/ProxyHatCom/go-sdk"
+)
+
+const usage = `usage: proxyhat <command> [flags]
+
+commands:
+  export    write sub-user proxy lists in common tool formats
+  probe     check gateway locations for latency and exit IP
+`
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "proxyhat:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("missing command")
+	}
+	switch args[0] {
+	case "export":
+		return runExport(args[1:], stdout)
+	case "probe":
+		return runProbe(args[1:], stdout)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return nil
+	}
+	fmt.Fprint(os.Stderr, usage)
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+// targetingList collects repeated -targeting flags such as
+// "country=US,city=new_york,type=mobile".
+type targetingList []proxyhat.Targeting
+
+func (l *targetingList) String() string {
+	return fmt.Sprint(len(*l), " targeting variants")
+}
+
+func (l *targetingList) Set(s string) error {
+	t, err := parseTargeting(s)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, t)
+	return nil
+}
+
+func parseTargeting(s string) (proxyhat.Targeting, error) {
+	var t proxyhat.Targeting
+	for _, pair := range strings.Split(s, ",") {
+		if pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return t, fmt.Errorf("invalid targeting %q: want key=value", pair)
+		}
+		switch key {
+		case "country":
+			t.Country = value
+		case "region":
+			t.Region = value
+		case "city":
+			t.City = value
+		case "isp":
+			t.ISP = value
+		case "zip", "zipcode":
+			t.Zipcode = value
+		case "type", "connection_type":
+			t.ConnectionType = value
+		default:
+			return t, fmt.Errorf("unknown targeting key %q", key)
+		}
+	}
+	return t, t.Validate()
+}
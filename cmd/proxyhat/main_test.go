@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	proxyhat "github.com/ProxyHatCom/go-sdk"
+)
+
+func TestParseTargeting(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    proxyhat.Targeting
+		wantErr bool
+	}{
+		{in: "", want: proxyhat.Targeting{}},
+		{in: "country=US", want: proxyhat.Targeting{Country: "US"}},
+		{
+			in:   "country=US,region=ny,city=new_york,isp=comcast,zip=10001,type=mobile",
+			want: proxyhat.Targeting{Country: "US", Region: "ny", City: "new_york", ISP: "comcast", Zipcode: "10001", ConnectionType: "mobile"},
+		},
+		{in: "zipcode=10001,connection_type=residential,", want: proxyhat.Targeting{Zipcode: "10001", ConnectionType: "residential"}},
+		{in: "country", wantErr: true},
+		{in: "planet=earth", wantErr: true},
+		{in: "country=u-s", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseTargeting(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseTargeting(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("parseTargeting(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
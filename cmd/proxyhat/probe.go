@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	proxyhat "github.com/ProxyHatCom/go-sdk"
+	"github.com/ProxyHatCom/go-sdk/internal/cliflag"
+)
+
+func runProbe(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("proxyhat probe", flag.ContinueOnError)
+	username := fs.String("username", os.Getenv("PROXYHAT_PROXY_USERNAME"), "sub-user proxy username")
+	password := fs.String("password", os.Getenv("PROXYHAT_PROXY_PASSWORD"), "sub-user proxy password")
+	format := fs.String("format", "json", "output format: json or csv")
+	echoURL := fs.String("echo-url", proxyhat.DefaultEchoURL, "endpoint that reports the exit IP")
+	timeout := fs.Duration("timeout", proxyhat.DefaultProbeTimeout, "timeout per probe")
+	concurrency := fs.Int("concurrency", proxyhat.DefaultProbeConcurrency, "number of probes run at once")
+	gateway := fs.String("gateway", "", "gateway host:port")
+	var locations targetingList
+	fs.Var(&locations, "targeting", "location to probe such as country=US,city=new_york (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *username == "" || *password == "" {
+		return errors.New("-username and -password (or PROXYHAT_PROXY_USERNAME and PROXYHAT_PROXY_PASSWORD) are required")
+	}
+	if *format != "json" && *format != "csv" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	if len(locations) == 0 {
+		locations = targetingList{{}}
+	}
+	var gatewayOpts []proxyhat.GatewayOption
+	if *gateway != "" {
+		gw, err := cliflag.ParseGateway(*gateway)
+		if err != nil {
+			return err
+		}
+		gatewayOpts = append(gatewayOpts, proxyhat.WithGateway(gw))
+	}
+
+	checker := proxyhat.NewHealthChecker(&proxyhat.SubUser{ProxyUsername: *username}, *password,
+		proxyhat.WithEchoURL(*echoURL),
+		proxyhat.WithProbeTimeout(*timeout),
+		proxyhat.WithProbeConcurrency(*concurrency),
+		proxyhat.WithProbeGateway(gatewayOpts...),
+	)
+	results := checker.ProbeAll(context.Background(), locations)
+
+	var err error
+	if *format == "csv" {
+		err = results.WriteCSV(stdout)
+	} else {
+		err = results.WriteJSON(stdout)
+	}
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for i := range results {
+		if !results[i].OK() {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d probes failed", failed, len(results))
+	}
+	return nil
+}
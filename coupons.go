@@ -17,7 +17,7 @@ type CouponParams struct {
 // Validate validates a coupon code.
 func (s *CouponsService) Validate(ctx context.Context, params CouponParams) (*CouponResponse, error) {
 	var result CouponResponse
-	err := s.client.doRequest(ctx, "POST", "coupon/validate", params, &result)
+	err := s.client.doRequest(ctx, "Coupons.Validate", "POST", "coupon/validate", params, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -27,7 +27,7 @@ func (s *CouponsService) Validate(ctx context.Context, params CouponParams) (*Co
 // Apply applies a coupon code.
 func (s *CouponsService) Apply(ctx context.Context, params CouponParams) (*CouponResponse, error) {
 	var result CouponResponse
-	err := s.client.doRequest(ctx, "POST", "coupon/apply", params, &result)
+	err := s.client.doRequest(ctx, "Coupons.Apply", "POST", "coupon/apply", params, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -38,7 +38,7 @@ func (s *CouponsService) Apply(ctx context.Context, params CouponParams) (*Coupo
 func (s *CouponsService) Redeem(ctx context.Context, code string) (*CouponResponse, error) {
 	var result CouponResponse
 	body := map[string]string{"code": code}
-	err := s.client.doRequest(ctx, "POST", "coupon/redeem", body, &result)
+	err := s.client.doRequest(ctx, "Coupons.Redeem", "POST", "coupon/redeem", body, &result)
 	if err != nil {
 		return nil, err
 	}
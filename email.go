@@ -15,7 +15,7 @@ type RequestEmailChangeParams struct {
 // RequestChange initiates an email change.
 func (s *EmailService) RequestChange(ctx context.Context, params RequestEmailChangeParams) (*EmailChangeResponse, error) {
 	var result EmailChangeResponse
-	err := s.client.doRequest(ctx, "POST", "profile/email/request-change", params, &result)
+	err := s.client.doRequest(ctx, "Email.RequestChange", "POST", "profile/email/request-change", params, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -26,7 +26,7 @@ func (s *EmailService) RequestChange(ctx context.Context, params RequestEmailCha
 func (s *EmailService) ConfirmChange(ctx context.Context, token string) (*EmailChangeResponse, error) {
 	var result EmailChangeResponse
 	body := map[string]string{"token": token}
-	err := s.client.doRequest(ctx, "POST", "profile/email/confirm-change", body, &result)
+	err := s.client.doRequest(ctx, "Email.ConfirmChange", "POST", "profile/email/confirm-change", body, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -36,7 +36,7 @@ func (s *EmailService) ConfirmChange(ctx context.Context, token string) (*EmailC
 // CancelChange cancels a pending email change.
 func (s *EmailService) CancelChange(ctx context.Context) (*EmailChangeResponse, error) {
 	var result EmailChangeResponse
-	err := s.client.doRequest(ctx, "POST", "profile/email/cancel-change", nil, &result)
+	err := s.client.doRequest(ctx, "Email.CancelChange", "POST", "profile/email/cancel-change", nil, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -46,7 +46,7 @@ func (s *EmailService) CancelChange(ctx context.Context) (*EmailChangeResponse,
 // ResendVerification resends the email verification.
 func (s *EmailService) ResendVerification(ctx context.Context) (*EmailChangeResponse, error) {
 	var result EmailChangeResponse
-	err := s.client.doRequest(ctx, "POST", "profile/email/resend-verification", nil, &result)
+	err := s.client.doRequest(ctx, "Email.ResendVerification", "POST", "profile/email/resend-verification", nil, &result)
 	if err != nil {
 		return nil, err
 	}
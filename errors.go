@@ -1,93 +1,249 @@
 package proxyhat
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
-	"strconv"
+	"sort"
+	"time"
 )
 
-// Error represents an API error response.
+// Sentinel errors matched by API errors with the corresponding status, so
+// that errors.Is(err, ErrNotFound) reports a 404 from any service method.
+var (
+	ErrBadRequest   = errors.New("proxyhat: bad request")
+	ErrUnauthorized = errors.New("proxyhat: unauthorized")
+	ErrForbidden    = errors.New("proxyhat: forbidden")
+	ErrNotFound     = errors.New("proxyhat: not found")
+	ErrConflict     = errors.New("proxyhat: conflict")
+	ErrValidation   = errors.New("proxyhat: validation failed")
+	ErrRateLimited  = errors.New("proxyhat: rate limited")
+	ErrServer       = errors.New("proxyhat: server error")
+)
+
+// Error represents an API error response. The more specific
+// RateLimitError and ValidationError also match *Error with errors.As.
 type Error struct {
 	Message    string `json:"message"`
 	StatusCode int    `json:"status_code"`
 	Errors     any    `json:"errors,omitempty"`
+	// RequestID is the X-Request-Id of the failed call. Include it in
+	// support requests.
+	RequestID string `json:"request_id,omitempty"`
+	// Method and Path identify the failed request; Path is the full URL
+	// path, e.g. "/v1/sub-users/123".
+	Method string `json:"method,omitempty"`
+	Path   string `json:"path,omitempty"`
+	// Body is the raw response body.
+	Body []byte `json:"-"`
 }
 
 func (e *Error) Error() string {
-	if e.Message != "" {
-		return fmt.Sprintf("proxyhat: %d %s", e.StatusCode, e.Message)
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+	if e.RequestID != "" {
+		return fmt.Sprintf("proxyhat: %d %s (request %s)", e.StatusCode, msg, e.RequestID)
+	}
+	return fmt.Sprintf("proxyhat: %d %s", e.StatusCode, msg)
+}
+
+// Is reports whether target is the sentinel error for e's status code.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrValidation:
+		return e.StatusCode == http.StatusUnprocessableEntity
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServer:
+		return e.StatusCode >= 500
 	}
-	return fmt.Sprintf("proxyhat: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	return false
 }
 
+// APIError is another name for Error, the category of errors returned by
+// the API, alongside TransportError and DecodeError. RateLimitError and
+// ValidationError embed it under this name, since a field named Error
+// would hide the Error method.
+type APIError = Error
+
 // RateLimitError is returned when the API rate limit is exceeded (HTTP 429).
 type RateLimitError struct {
-	Message    string `json:"message"`
-	StatusCode int    `json:"status_code"`
-	Errors     any    `json:"errors,omitempty"`
-	RetryAfter int    `json:"retry_after"`
+	APIError
+	// RetryAfter is the number of seconds the server asked to wait, from the
+	// Retry-After header in either its seconds or HTTP-date form.
+	RetryAfter int `json:"retry_after"`
+}
+
+// As makes errors.As(err, &target) with target of type *Error find the
+// embedded Error.
+func (e *RateLimitError) As(target any) bool {
+	return asError(&e.APIError, target)
 }
 
-func (e *RateLimitError) Error() string {
-	if e.Message != "" {
-		return fmt.Sprintf("proxyhat: %d %s", e.StatusCode, e.Message)
+// ValidationError is returned when the API rejects the request parameters
+// (HTTP 422).
+type ValidationError struct {
+	APIError
+	// Fields maps parameter names to their error messages. Errors not tied
+	// to a parameter are under the empty key.
+	Fields map[string][]string `json:"-"`
+}
+
+// FieldErrors returns the error messages for field, or nil if it has none.
+func (e *ValidationError) FieldErrors(field string) []string {
+	return e.Fields[field]
+}
+
+// FieldNames returns the names of the fields with errors in sorted order.
+func (e *ValidationError) FieldNames() []string {
+	names := make([]string, 0, len(e.Fields))
+	for name := range e.Fields {
+		names = append(names, name)
 	}
-	return fmt.Sprintf("proxyhat: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	sort.Strings(names)
+	return names
 }
 
-// IsAuthenticationError returns true if the error is a 401 Unauthorized.
-func IsAuthenticationError(err error) bool {
-	var e *Error
-	if errors.As(err, &e) {
-		return e.StatusCode == http.StatusUnauthorized
+// As makes errors.As(err, &target) with target of type *Error find the
+// embedded Error.
+func (e *ValidationError) As(target any) bool {
+	return asError(&e.APIError, target)
+}
+
+func asError(e *Error, target any) bool {
+	if p, ok := target.(**Error); ok {
+		*p = e
+		return true
 	}
-	var rle *RateLimitError
-	if errors.As(err, &rle) {
-		return rle.StatusCode == http.StatusUnauthorized
+	return false
+}
+
+// TransportError is returned when a request could not be sent or its
+// response could not be read, e.g. on DNS, connection or TLS failures.
+type TransportError struct {
+	Method string
+	// Path is the full URL path of the request.
+	Path string
+	Err  error
+}
+
+func (e *TransportError) Error() string {
+	return "proxyhat: request failed: " + e.Err.Error()
+}
+
+func (e *TransportError) Unwrap() error { return e.Err }
+
+// Timeout reports whether the request timed out.
+func (e *TransportError) Timeout() bool {
+	return IsTimeout(e.Err)
+}
+
+// DecodeError is returned when a successful response could not be decoded
+// into the result type.
+type DecodeError struct {
+	StatusCode  int
+	ContentType string
+	// Body is the raw response body.
+	Body []byte
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	return "proxyhat: failed to decode response: " + e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// IsRetryable reports whether the call that returned err may succeed if
+// repeated later: timeouts, network failures, calls rejected by an open
+// circuit breaker or a rate limiter wait, and API responses with status
+// 408, 409, 429 or 5xx other than 501. Canceled calls, decode failures and
+// other API errors are not retryable.
+func IsRetryable(err error) bool {
+	if err == nil || IsCanceled(err) {
+		return false
+	}
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrRateLimitWait) {
+		return true
+	}
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooManyRequests:
+			return true
+		}
+		return apiErr.StatusCode >= 500 && apiErr.StatusCode != http.StatusNotImplemented
+	}
+	var decodeErr *DecodeError
+	if errors.As(err, &decodeErr) {
+		return false
+	}
+	var transportErr *TransportError
+	var netErr net.Error
+	return errors.As(err, &transportErr) || errors.As(err, &netErr) || IsTimeout(err)
+}
+
+// IsTimeout reports whether err is a timeout: an exceeded context deadline,
+// a network timeout, or an API response with status 408 or 504.
+func IsTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusRequestTimeout || apiErr.StatusCode == http.StatusGatewayTimeout
 	}
 	return false
 }
 
+// IsCanceled reports whether err was caused by canceling the call's
+// context.
+func IsCanceled(err error) bool {
+	return errors.Is(err, context.Canceled)
+}
+
+// IsAuthenticationError returns true if the error is a 401 Unauthorized.
+func IsAuthenticationError(err error) bool {
+	return errors.Is(err, ErrUnauthorized)
+}
+
 // IsPermissionError returns true if the error is a 403 Forbidden.
 func IsPermissionError(err error) bool {
-	var e *Error
-	if errors.As(err, &e) {
-		return e.StatusCode == http.StatusForbidden
-	}
-	return false
+	return errors.Is(err, ErrForbidden)
 }
 
 // IsNotFoundError returns true if the error is a 404 Not Found.
 func IsNotFoundError(err error) bool {
-	var e *Error
-	if errors.As(err, &e) {
-		return e.StatusCode == http.StatusNotFound
-	}
-	return false
+	return errors.Is(err, ErrNotFound)
 }
 
 // IsValidationError returns true if the error is a 422 Unprocessable Entity.
 func IsValidationError(err error) bool {
-	var e *Error
-	if errors.As(err, &e) {
-		return e.StatusCode == http.StatusUnprocessableEntity
-	}
-	return false
+	return errors.Is(err, ErrValidation)
 }
 
 // IsRateLimitError returns true if the error is a 429 Too Many Requests.
 func IsRateLimitError(err error) bool {
-	var rle *RateLimitError
-	if errors.As(err, &rle) {
-		return true
-	}
-	var e *Error
-	if errors.As(err, &e) {
-		return e.StatusCode == http.StatusTooManyRequests
-	}
-	return false
+	return errors.Is(err, ErrRateLimited)
 }
 
 // AsRateLimitError extracts a RateLimitError from err if present.
@@ -99,43 +255,89 @@ func AsRateLimitError(err error) (*RateLimitError, bool) {
 	return nil, false
 }
 
+// AsValidationError extracts a ValidationError from err if present.
+func AsValidationError(err error) (*ValidationError, bool) {
+	var ve *ValidationError
+	if errors.As(err, &ve) {
+		return ve, true
+	}
+	return nil, false
+}
+
 func checkResponse(resp *http.Response, body []byte) error {
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
 		return nil
 	}
 
-	msg := parseErrorMessage(body)
+	e := Error{
+		Message:    parseErrorMessage(body),
+		StatusCode: resp.StatusCode,
+		RequestID:  resp.Header.Get("X-Request-Id"),
+		Body:       body,
+	}
+	if resp.Request != nil {
+		e.Method = resp.Request.Method
+		e.Path = resp.Request.URL.Path
+	}
 
-	var errs any
 	var raw map[string]json.RawMessage
 	if json.Unmarshal(body, &raw) == nil {
-		if e, ok := raw["errors"]; ok {
+		if errs, ok := raw["errors"]; ok {
 			var parsed any
-			if json.Unmarshal(e, &parsed) == nil {
-				errs = parsed
+			if json.Unmarshal(errs, &parsed) == nil {
+				e.Errors = parsed
 			}
 		}
 	}
 
-	if resp.StatusCode == http.StatusTooManyRequests {
-		rle := &RateLimitError{
-			Message:    msg,
-			StatusCode: resp.StatusCode,
-			Errors:     errs,
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		rle := &RateLimitError{APIError: e}
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			rle.RetryAfter = int((d + time.Second - 1) / time.Second)
 		}
-		if ra := resp.Header.Get("Retry-After"); ra != "" {
-			if v, err := strconv.Atoi(ra); err == nil {
-				rle.RetryAfter = v
+		return rle
+	case http.StatusUnprocessableEntity:
+		return &ValidationError{APIError: e, Fields: parseFieldErrors(e.Errors)}
+	}
+	return &e
+}
+
+// parseFieldErrors converts the "errors" member of a validation response,
+// either {"field": ["message", ...]} or {"field": "message"}, to field
+// errors. A plain list or string of messages is kept under the empty key.
+func parseFieldErrors(errs any) map[string][]string {
+	fields := map[string][]string{}
+	switch errs := errs.(type) {
+	case map[string]any:
+		for name, v := range errs {
+			if msgs := errorMessages(v); len(msgs) > 0 {
+				fields[name] = msgs
 			}
 		}
-		return rle
+	case nil:
+	default:
+		if msgs := errorMessages(errs); len(msgs) > 0 {
+			fields[""] = msgs
+		}
 	}
+	return fields
+}
 
-	return &Error{
-		Message:    msg,
-		StatusCode: resp.StatusCode,
-		Errors:     errs,
+func errorMessages(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var msgs []string
+		for _, m := range v {
+			msgs = append(msgs, errorMessages(m)...)
+		}
+		return msgs
+	case nil:
+		return nil
 	}
+	return []string{fmt.Sprint(v)}
 }
 
 func parseErrorMessage(body []byte) string {
@@ -0,0 +1,227 @@
+package proxyhat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestError_Context(t *testing.T) {
+	client, mux, cleanup := setupTest()
+	defer cleanup()
+	mux.HandleFunc("/sub-users/missing", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req-1")
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not found"})
+	})
+
+	_, err := client.SubUsers.Get(context.Background(), "missing")
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want *Error", err)
+	}
+	if apiErr.Method != "GET" || apiErr.Path != "/sub-users/missing" || apiErr.RequestID != "req-1" {
+		t.Errorf("Error = %+v", apiErr)
+	}
+	if string(apiErr.Body) != `{"message":"Not found"}`+"\n" {
+		t.Errorf("Body = %q", apiErr.Body)
+	}
+}
+
+func TestError_Sentinels(t *testing.T) {
+	tests := []struct {
+		status   int
+		sentinel error
+	}{
+		{400, ErrBadRequest},
+		{401, ErrUnauthorized},
+		{403, ErrForbidden},
+		{404, ErrNotFound},
+		{409, ErrConflict},
+		{422, ErrValidation},
+		{429, ErrRateLimited},
+		{500, ErrServer},
+		{503, ErrServer},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+		err := fmt.Errorf("wrapped: %w", checkResponse(resp, []byte(`{}`)))
+		if !errors.Is(err, tt.sentinel) {
+			t.Errorf("%d: errors.Is(%v) = false", tt.status, tt.sentinel)
+		}
+		if tt.status != 404 && errors.Is(err, ErrNotFound) {
+			t.Errorf("%d: matched ErrNotFound", tt.status)
+		}
+	}
+}
+
+func TestRateLimitError_UnifiesWithError(t *testing.T) {
+	resp := &http.Response{StatusCode: 429, Header: http.Header{"Retry-After": {"5"}}}
+	err := checkResponse(resp, []byte(`{"message":"Slow down"}`))
+
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 429 || apiErr.Message != "Slow down" {
+		t.Fatalf("errors.As(*Error) = %+v", apiErr)
+	}
+	rle, ok := AsRateLimitError(err)
+	if !ok || rle.RetryAfter != 5 {
+		t.Fatalf("AsRateLimitError = %+v, %v", rle, ok)
+	}
+	if got, want := err.Error(), "proxyhat: 429 Slow down"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if IsPermissionError(err) || !IsRateLimitError(err) {
+		t.Error("helpers misclassified a 429")
+	}
+
+	data, _ := json.Marshal(rle)
+	var fields map[string]any
+	json.Unmarshal(data, &fields)
+	if fields["message"] != "Slow down" || fields["retry_after"] != float64(5) {
+		t.Errorf("JSON = %s", data)
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	client, mux, cleanup := setupTest()
+	defer cleanup()
+	mux.HandleFunc("/sub-users", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{
+			"message": "The given data was invalid.",
+			"errors": map[string]any{
+				"proxy_password": []string{"The proxy password must be at least 8 characters.", "The proxy password is too common."},
+				"name":           "The name has already been taken.",
+			},
+		})
+	})
+
+	_, err := client.SubUsers.Create(context.Background(), CreateSubUserParams{ProxyPassword: "x"})
+	ve, ok := AsValidationError(err)
+	if !ok {
+		t.Fatalf("err = %v, want *ValidationError", err)
+	}
+	if got := ve.FieldErrors("proxy_password"); len(got) != 2 {
+		t.Errorf("FieldErrors(proxy_password) = %q", got)
+	}
+	if got := ve.FieldErrors("name"); !reflect.DeepEqual(got, []string{"The name has already been taken."}) {
+		t.Errorf("FieldErrors(name) = %q", got)
+	}
+	if got := ve.FieldErrors("email"); got != nil {
+		t.Errorf("FieldErrors(email) = %q, want nil", got)
+	}
+	if got := ve.FieldNames(); !reflect.DeepEqual(got, []string{"name", "proxy_password"}) {
+		t.Errorf("FieldNames() = %q", got)
+	}
+	if !IsValidationError(err) || !errors.Is(err, ErrValidation) {
+		t.Error("not classified as a validation error")
+	}
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Method != "POST" {
+		t.Errorf("errors.As(*Error) = %+v", apiErr)
+	}
+}
+
+func TestParseFieldErrors(t *testing.T) {
+	tests := []struct {
+		in   string
+		want map[string][]string
+	}{
+		{`null`, map[string][]string{}},
+		{`["first", "second"]`, map[string][]string{"": {"first", "second"}}},
+		{`"only"`, map[string][]string{"": {"only"}}},
+		{`{"a": [], "b": ["x"]}`, map[string][]string{"b": {"x"}}},
+	}
+	for _, tt := range tests {
+		var errs any
+		json.Unmarshal([]byte(tt.in), &errs)
+		if got := parseFieldErrors(errs); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseFieldErrors(%s) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestTransportError(t *testing.T) {
+	client := NewClient("key", WithBaseURL("http://127.0.0.1:1"))
+	_, err := client.SubUsers.List(context.Background())
+	var te *TransportError
+	if !errors.As(err, &te) {
+		t.Fatalf("err = %v, want *TransportError", err)
+	}
+	if te.Method != "GET" || te.Path != "/sub-users" {
+		t.Errorf("TransportError = %+v", te)
+	}
+	if !IsRetryable(err) || IsTimeout(err) || IsCanceled(err) {
+		t.Errorf("classified as retryable=%v timeout=%v canceled=%v", IsRetryable(err), IsTimeout(err), IsCanceled(err))
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	client, mux, cleanup := setupTest()
+	defer cleanup()
+	mux.HandleFunc("/sub-users", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html>maintenance</html>"))
+	})
+
+	_, err := client.SubUsers.List(context.Background())
+	var de *DecodeError
+	if !errors.As(err, &de) {
+		t.Fatalf("err = %v, want *DecodeError", err)
+	}
+	if de.StatusCode != 200 || de.ContentType != "text/html" || string(de.Body) != "<html>maintenance</html>" {
+		t.Errorf("DecodeError = %+v", de)
+	}
+	if IsRetryable(err) {
+		t.Error("decode errors should not be retryable")
+	}
+}
+
+func TestClassifiers(t *testing.T) {
+	apiErr := func(status int) error {
+		return checkResponse(&http.Response{StatusCode: status, Header: http.Header{}}, nil)
+	}
+	tests := []struct {
+		name                         string
+		err                          error
+		retryable, timeout, canceled bool
+	}{
+		{"nil", nil, false, false, false},
+		{"canceled", &TransportError{Err: context.Canceled}, false, false, true},
+		{"deadline", fmt.Errorf("wait: %w", context.DeadlineExceeded), true, true, false},
+		{"net timeout", &TransportError{Err: timeoutError{}}, true, true, false},
+		{"net error", timeoutError{}, true, true, false},
+		{"400", apiErr(400), false, false, false},
+		{"404", apiErr(404), false, false, false},
+		{"408", apiErr(408), true, true, false},
+		{"409", apiErr(409), true, false, false},
+		{"422", apiErr(422), false, false, false},
+		{"429", apiErr(429), true, false, false},
+		{"500", apiErr(500), true, false, false},
+		{"501", apiErr(501), false, false, false},
+		{"504", apiErr(504), true, true, false},
+		{"after retries", &RetryError{Err: apiErr(503)}, true, false, false},
+		{"rate limit wait", fmt.Errorf("%w: need 1s", ErrRateLimitWait), true, false, false},
+		{"decode", &DecodeError{Err: errors.New("bad json")}, false, false, false},
+		{"other", errors.New("boom"), false, false, false},
+	}
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.retryable {
+			t.Errorf("%s: IsRetryable = %v, want %v", tt.name, got, tt.retryable)
+		}
+		if got := IsTimeout(tt.err); got != tt.timeout {
+			t.Errorf("%s: IsTimeout = %v, want %v", tt.name, got, tt.timeout)
+		}
+		if got := IsCanceled(tt.err); got != tt.canceled {
+			t.Errorf("%s: IsCanceled = %v, want %v", tt.name, got, tt.canceled)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package proxyhat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// WithRequestCoalescing makes concurrent identical GET calls, with the same
+// path, query and headers, share one API request. Every caller receives its
+// own copy of the decoded result and may cancel its wait independently; the
+// request is canceled once all callers have given up, and is bounded by the
+// first caller's deadline. Coalesced calls are checked against the cache
+// first when WithCache is set, and retried as one under WithRetryPolicy.
+func WithRequestCoalescing() Option {
+	return func(c *Client) {
+		c.coalesce = true
+	}
+}
+
+func coalescingMiddleware() Middleware {
+	var flights flightGroup
+	return func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			if req.Method != http.MethodGet || req.raw {
+				return next(req)
+			}
+			return flights.do(coalescingKey(req), req, next)
+		}
+	}
+}
+
+// coalescingKey identifies identical requests. Headers are included
+// because middleware such as the cache sets conditional ones.
+func coalescingKey(req *Request) string {
+	var b strings.Builder
+	b.WriteString(req.Method + " " + strings.Trim(req.Path, "/") + "?" + req.Params.Encode())
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b.WriteString("\n" + name + ": " + strings.Join(req.Header[name], ", "))
+	}
+	if req.Result == nil {
+		// The shared call only keeps the payload if its first caller
+		// wants a result.
+		b.WriteString("\nno-result")
+	}
+	return b.String()
+}
+
+// flightGroup runs concurrent identical API calls once and shares the
+// result. The shared call runs detached from any single caller's context
+// and is canceled only when every caller has given up. It keeps the first
+// caller's deadline, so that rate limiting and retries still honor it.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done    chan struct{}
+	waiters int
+	cancel  context.CancelFunc
+
+	resp *Response
+	// result is the shared call's Result, of the first caller's type.
+	result any
+	// payload is the payload the API sent, so that each caller can decode
+	// it into its own Result.
+	payload json.RawMessage
+	err     error
+}
+
+// do performs req through next, or waits for an in-flight call with the
+// same key, and decodes the result into req.Result.
+func (g *flightGroup) do(key string, req *Request, next Handler) (*Response, error) {
+	ctx := req.Context()
+
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*flightCall{}
+	}
+	c, ok := g.calls[key]
+	if !ok {
+		var callCtx context.Context
+		var cancel context.CancelFunc
+		if deadline, ok := ctx.Deadline(); ok {
+			callCtx, cancel = context.WithDeadline(context.WithoutCancel(ctx), deadline)
+		} else {
+			callCtx, cancel = context.WithCancel(context.WithoutCancel(ctx))
+		}
+		c = &flightCall{done: make(chan struct{}), cancel: cancel}
+		g.calls[key] = c
+		shared := req.WithContext(callCtx)
+		if req.Result != nil {
+			c.result = newResult(req.Result)
+			shared.Result = c.result
+		}
+		go g.run(key, c, shared, next)
+	}
+	c.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		g.mu.Lock()
+		if c.waiters--; c.waiters == 0 {
+			// Nobody is left to receive the result.
+			c.cancel()
+			if g.calls[key] == c {
+				delete(g.calls, key)
+			}
+		}
+		g.mu.Unlock()
+		return nil, ctx.Err()
+	}
+
+	if c.err == nil && req.Result != nil && c.payload != nil {
+		if err := json.Unmarshal(c.payload, req.Result); err != nil {
+			return c.resp, payloadError(c.resp, c.payload, err)
+		}
+	}
+	return c.resp, c.err
+}
+
+// run performs the shared call. A panic in next is returned to every
+// caller as an error, as there is no caller goroutine to unwind.
+func (g *flightGroup) run(key string, c *flightCall, req *Request, next Handler) {
+	defer func() {
+		if v := recover(); v != nil {
+			c.resp, c.payload = nil, nil
+			c.err = fmt.Errorf("proxyhat: panic in shared call: %v\n%s", v, debug.Stack())
+		}
+		c.cancel()
+		g.mu.Lock()
+		if g.calls[key] == c {
+			delete(g.calls, key)
+		}
+		g.mu.Unlock()
+		close(c.done)
+	}()
+	c.resp, c.err = next(req)
+	if c.err == nil && c.result != nil {
+		c.payload, c.err = responsePayload(c.resp, c.result)
+	}
+}
+
+// responsePayload returns the payload of resp for callers to decode into
+// their own Result: the bytes the API sent, or result encoded if
+// middleware answered without sending the request. It is nil for 304
+// responses, which leave each caller's cached copy in place.
+func responsePayload(resp *Response, result any) (json.RawMessage, error) {
+	switch {
+	case resp != nil && resp.payload != nil:
+		return resp.payload, nil
+	case resp != nil && resp.Response != nil && resp.StatusCode == http.StatusNotModified:
+		return nil, nil
+	}
+	payload, err := json.Marshal(result)
+	if err != nil {
+		return nil, payloadError(resp, nil, err)
+	}
+	return payload, nil
+}
+
+// payloadError reports a payload that could not be decoded or encoded.
+// resp may be nil, as middleware may answer without a response.
+func payloadError(resp *Response, payload []byte, err error) *DecodeError {
+	e := &DecodeError{Body: payload, Err: err}
+	if resp != nil && resp.Response != nil {
+		e.StatusCode = resp.StatusCode
+		e.ContentType = resp.Header.Get("Content-Type")
+	}
+	return e
+}
+
+// newResult returns a new value of the type result points to, so that
+// middleware below a shared call sees a Result of its caller's type.
+func newResult(result any) any {
+	if t := reflect.TypeOf(result); t != nil && t.Kind() == reflect.Pointer {
+		return reflect.New(t.Elem()).Interface()
+	}
+	return new(json.RawMessage)
+}
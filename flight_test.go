@@ -0,0 +1,341 @@
+package proxyhat
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCoalescing_SharesConcurrentGets(t *testing.T) {
+	client, mux, cleanup := setupTest(WithRequestCoalescing())
+	defer cleanup()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	mux.HandleFunc("/auth/user", func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		writePayload(w, User{Email: "a@example.com"})
+	})
+
+	const n = 20
+	var wg sync.WaitGroup
+	users := make([]*User, n)
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			users[i], errs[i] = client.Auth.User(context.Background())
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("calls = %d, want 1", calls.Load())
+	}
+	for i := 0; i < n; i++ {
+		if errs[i] != nil || users[i] == nil || users[i].Email != "a@example.com" {
+			t.Fatalf("caller %d: %+v, %v", i, users[i], errs[i])
+		}
+	}
+	users[0].Email = "changed"
+	if users[1].Email != "a@example.com" {
+		t.Error("callers share a result value")
+	}
+
+	// Later calls are not coalesced with finished ones.
+	if _, err := client.Auth.User(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("calls = %d, want 2", calls.Load())
+	}
+}
+
+func TestCoalescing_DistinctRequests(t *testing.T) {
+	client, mux, cleanup := setupTest(WithRequestCoalescing())
+	defer cleanup()
+
+	var calls atomic.Int32
+	var wg sync.WaitGroup
+	wg.Add(2)
+	mux.HandleFunc("/locations/countries", func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		wg.Done()
+		wg.Wait()
+		writeData(w, []Country{{Code: r.URL.Query().Get("name")}})
+	})
+
+	results := make(chan string, 2)
+	for _, name := range []string{"US", "DE"} {
+		name := name
+		go func() {
+			countries, err := client.Locations.Countries(context.Background(), &LocationParams{Name: String(name)})
+			if err != nil || len(countries) != 1 {
+				results <- "error"
+				return
+			}
+			results <- countries[0].Code
+		}()
+	}
+	got := map[string]bool{<-results: true, <-results: true}
+	if !got["US"] || !got["DE"] || calls.Load() != 2 {
+		t.Errorf("results = %v, calls = %d", got, calls.Load())
+	}
+}
+
+func TestCoalescing_IndependentCancellation(t *testing.T) {
+	client, mux, cleanup := setupTest(WithRequestCoalescing())
+	defer cleanup()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var canceled atomic.Bool
+	mux.HandleFunc("/auth/user", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		select {
+		case <-release:
+			writePayload(w, User{Email: "a@example.com"})
+		case <-r.Context().Done():
+			canceled.Store(true)
+		}
+	})
+
+	// The first caller gives up, but the request keeps going for the second.
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	err1 := make(chan error, 1)
+	go func() {
+		_, err := client.Auth.User(ctx1)
+		err1 <- err
+	}()
+	<-started
+	done2 := make(chan error, 1)
+	go func() {
+		_, err := client.Auth.User(context.Background())
+		done2 <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel1()
+	if err := <-err1; !errors.Is(err, context.Canceled) {
+		t.Errorf("first caller err = %v, want context.Canceled", err)
+	}
+	close(release)
+	if err := <-done2; err != nil {
+		t.Errorf("second caller err = %v", err)
+	}
+	if canceled.Load() {
+		t.Error("request canceled while a caller was still waiting")
+	}
+}
+
+func TestCoalescing_CanceledWhenAllCallersLeave(t *testing.T) {
+	client, mux, cleanup := setupTest(WithRequestCoalescing())
+	defer cleanup()
+
+	started := make(chan struct{})
+	serverCanceled := make(chan struct{})
+	mux.HandleFunc("/auth/user", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+		close(serverCanceled)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		_, err := client.Auth.User(ctx)
+		errc <- err
+	}()
+	<-started
+	cancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	select {
+	case <-serverCanceled:
+	case <-time.After(time.Second):
+		t.Error("shared request was not canceled")
+	}
+}
+
+func TestCoalescing_KeepsFirstCallerDeadline(t *testing.T) {
+	var hasDeadline atomic.Bool
+	client, mux, cleanup := setupTest(WithRequestCoalescing(), WithMiddleware(func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			_, ok := req.Context().Deadline()
+			hasDeadline.Store(ok)
+			return next(req)
+		}
+	}))
+	defer cleanup()
+	mux.HandleFunc("/auth/user", func(w http.ResponseWriter, r *http.Request) {
+		writePayload(w, User{})
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if _, err := client.Auth.User(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !hasDeadline.Load() {
+		t.Error("shared call lost the caller's deadline")
+	}
+}
+
+func TestCoalescing_FollowersDecodeAPIPayload(t *testing.T) {
+	client, mux, cleanup := setupTest(WithRequestCoalescing())
+	defer cleanup()
+
+	var calls atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	mux.HandleFunc("/locations/countries", func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		close(started)
+		<-release
+		writeData(w, []map[string]any{{"code": "US", "name": "United States", "cities": 42}})
+	})
+
+	leader := make(chan error, 1)
+	go func() {
+		_, err := client.Locations.Countries(context.Background(), nil)
+		leader <- err
+	}()
+	<-started
+	follower := make(chan error, 1)
+	var got []map[string]any
+	go func() {
+		req, err := client.NewRequest(context.Background(), http.MethodGet, "locations/countries", nil, nil)
+		if err == nil {
+			_, err = client.Do(req, &got)
+		}
+		follower <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	if err := <-leader; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-follower; err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("calls = %d, want 1", calls.Load())
+	}
+	// The follower sees what the API sent, not the leader's Country shape.
+	want := map[string]any{"code": "US", "name": "United States", "cities": float64(42)}
+	if len(got) != 1 || !reflect.DeepEqual(got[0], want) {
+		t.Errorf("follower got %v, want [%v]", got, want)
+	}
+}
+
+func TestCoalescing_NilResponseFromMiddleware(t *testing.T) {
+	client, _, cleanup := setupTest(WithRequestCoalescing(), WithMiddleware(func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			return nil, nil
+		}
+	}))
+	defer cleanup()
+
+	if _, err := client.Auth.User(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCoalescing_WithCache(t *testing.T) {
+	client, mux, cleanup := setupTest(WithRequestCoalescing(), WithCache(NewLRUCache(0)))
+	defer cleanup()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	mux.HandleFunc("/locations/countries", func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		writeData(w, []Country{{Code: "US"}})
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if countries, err := client.Locations.Countries(context.Background(), nil); err != nil || len(countries) != 1 {
+				t.Errorf("Countries = %v, %v", countries, err)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if _, err := client.Locations.Countries(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("calls = %d, want 1", calls.Load())
+	}
+}
+
+func TestCoalescing_CannedResponse(t *testing.T) {
+	release := make(chan struct{})
+	client, _, cleanup := setupTest(WithRequestCoalescing(), WithMiddleware(func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			if req.Path == "auth/user" {
+				<-release
+				*req.Result.(*User) = User{Email: "canned@example.com"}
+				return &Response{Response: &http.Response{StatusCode: http.StatusOK}}, nil
+			}
+			return next(req)
+		}
+	}))
+	defer cleanup()
+
+	const n = 5
+	var wg sync.WaitGroup
+	users := make([]*User, n)
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			users[i], errs[i] = client.Auth.User(context.Background())
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		if errs[i] != nil || users[i] == nil || users[i].Email != "canned@example.com" {
+			t.Fatalf("caller %d: %+v, %v", i, users[i], errs[i])
+		}
+	}
+}
+
+func TestCoalescing_PanicInMiddleware(t *testing.T) {
+	client, _, cleanup := setupTest(WithRequestCoalescing(), WithMiddleware(func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			panic("boom")
+		}
+	}))
+	defer cleanup()
+
+	_, err := client.Auth.User(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "panic in shared call: boom") {
+		t.Fatalf("err = %v", err)
+	}
+	// The failed call is not left in flight.
+	if _, err := client.Auth.User(context.Background()); err == nil {
+		t.Fatal("second call: expected error")
+	}
+}
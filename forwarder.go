@@ -0,0 +1,357 @@
+package proxyhat
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ForwarderOption configures a Forwarder.
+type ForwarderOption func(*Forwarder)
+
+// WithUpstream sets the options used to reach the ProxyHat gateway.
+func WithUpstream(opts ...GatewayOption) ForwarderOption {
+	return func(f *Forwarder) {
+		f.upstream = append(f.upstream, opts...)
+	}
+}
+
+// WithLocalAuth requires local clients to authenticate with the given
+// credentials. They are checked locally and never sent to the gateway.
+func WithLocalAuth(username, password string) ForwarderOption {
+	return func(f *Forwarder) {
+		f.localUser = username
+		f.localPass = password
+	}
+}
+
+// Forwarder is a local HTTP proxy that forwards every request to the
+// ProxyHat gateway with the sub-user credentials and targeting injected. It
+// lets tools that cannot send proxy credentials use the gateway.
+//
+// Plain HTTP requests are forwarded through a NewProxyTransport; CONNECT
+// requests are tunnelled through a CONNECT to the gateway. A forwarder built
+// with NewTenantForwarder maps each local client to its own sub-user.
+type Forwarder struct {
+	upstream  []GatewayOption
+	localUser string
+	localPass string
+
+	transport *http.Transport
+	tenants   *TenantRegistry
+	dialer    *net.Dialer
+}
+
+// NewForwarder creates a Forwarder for the given sub-user. Serve it with an
+// http.Server bound to a local address.
+func NewForwarder(subUser *SubUser, password string, t Targeting, opts ...ForwarderOption) (*Forwarder, error) {
+	f := &Forwarder{}
+	for _, opt := range opts {
+		opt(f)
+	}
+	cfg := newGatewayConfig(f.upstream)
+	transport, err := NewProxyTransport(subUser, password, t, f.upstream...)
+	if err != nil {
+		return nil, err
+	}
+	f.transport = transport
+	f.dialer = &net.Dialer{Timeout: cfg.dialTimeout, KeepAlive: 30 * time.Second}
+	return f, nil
+}
+
+// NewTenantForwarder creates a Forwarder that authenticates each local client
+// against the registry and forwards its traffic as the mapped sub-user.
+// WithLocalAuth is ignored; gateway options come from the registry.
+func NewTenantForwarder(tenants *TenantRegistry, opts ...ForwarderOption) *Forwarder {
+	f := &Forwarder{}
+	for _, opt := range opts {
+		opt(f)
+	}
+	f.tenants = tenants
+	f.dialer = &net.Dialer{Timeout: tenants.cfg.dialTimeout, KeepAlive: 30 * time.Second}
+	return f
+}
+
+// ServeHTTP implements http.Handler.
+func (f *Forwarder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	transport, stats, ok := f.route(w, r)
+	if !ok {
+		return
+	}
+	if stats != nil {
+		defer stats.release()
+	}
+	if r.Method == http.MethodConnect {
+		f.serveConnect(w, r, transport, stats)
+		return
+	}
+	f.serveHTTP(w, r, transport, stats)
+}
+
+// route authenticates r and picks the transport to forward it with. It
+// writes the error response itself when r is rejected.
+func (f *Forwarder) route(w http.ResponseWriter, r *http.Request) (*http.Transport, *tenantStats, bool) {
+	if f.tenants == nil {
+		if !f.authorized(r) {
+			requireProxyAuth(w)
+			return nil, nil, false
+		}
+		return f.transport, nil, true
+	}
+
+	user, pass, _ := proxyBasicAuth(r)
+	tenant, ok := f.tenants.authenticate(user, pass)
+	if !ok {
+		requireProxyAuth(w)
+		return nil, nil, false
+	}
+	if !tenant.stats.acquire(tenant.maxConcurrent) {
+		http.Error(w, "tenant concurrency limit reached", http.StatusTooManyRequests)
+		return nil, nil, false
+	}
+	return tenant.transport, tenant.stats, true
+}
+
+func requireProxyAuth(w http.ResponseWriter) {
+	w.Header().Set("Proxy-Authenticate", `Basic realm="proxyhat-forward"`)
+	http.Error(w, "proxy authentication required", http.StatusProxyAuthRequired)
+}
+
+func (f *Forwarder) authorized(r *http.Request) bool {
+	if f.localUser == "" && f.localPass == "" {
+		return true
+	}
+	user, pass, ok := proxyBasicAuth(r)
+	if !ok {
+		return false
+	}
+	userOK := subtle.ConstantTimeCompare([]byte(user), []byte(f.localUser)) == 1
+	passOK := subtle.ConstantTimeCompare([]byte(pass), []byte(f.localPass)) == 1
+	return userOK && passOK
+}
+
+// proxyBasicAuth parses the Proxy-Authorization header of r.
+func proxyBasicAuth(r *http.Request) (username, password string, ok bool) {
+	encoded, found := strings.CutPrefix(r.Header.Get("Proxy-Authorization"), "Basic ")
+	if !found {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", false
+	}
+	return strings.Cut(string(decoded), ":")
+}
+
+// hopHeaders are removed when forwarding, per RFC 7230 section 6.1.
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+func removeHopHeaders(h http.Header) {
+	for _, f := range h.Values("Connection") {
+		for _, name := range strings.Split(f, ",") {
+			h.Del(strings.TrimSpace(name))
+		}
+	}
+	for _, name := range hopHeaders {
+		h.Del(name)
+	}
+}
+
+func (f *Forwarder) serveHTTP(w http.ResponseWriter, r *http.Request, transport *http.Transport, stats *tenantStats) {
+	if r.URL.Host == "" {
+		http.Error(w, "request must use an absolute URL", http.StatusBadRequest)
+		return
+	}
+
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	removeHopHeaders(out.Header)
+	if out.Body != nil && out.Body != http.NoBody {
+		out.Body = &countingReadCloser{ReadCloser: out.Body, n: stats.sentCounter()}
+	}
+
+	resp, err := transport.RoundTrip(out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	removeHopHeaders(resp.Header)
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(&countingWriter{Writer: w, n: stats.receivedCounter()}, resp.Body)
+}
+
+func (f *Forwarder) serveConnect(w http.ResponseWriter, r *http.Request, transport *http.Transport, stats *tenantStats) {
+	upstream, upstreamBuf, err := f.dialTunnel(r, transport)
+	if err != nil {
+		if herr, ok := err.(*tunnelError); ok {
+			http.Error(w, herr.Error(), herr.status)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		upstream.Close()
+		http.Error(w, "hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+	client, clientBuf, err := hj.Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	if _, err := io.WriteString(client, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		client.Close()
+		upstream.Close()
+		return
+	}
+	pipe(client, clientBuf.Reader, upstream, upstreamBuf, stats)
+}
+
+// tunnelError carries the status the gateway answered a CONNECT with.
+type tunnelError struct {
+	status int
+}
+
+func (e *tunnelError) Error() string {
+	return fmt.Sprintf("proxyhat: gateway refused tunnel: %d %s", e.status, http.StatusText(e.status))
+}
+
+// dialTunnel opens a CONNECT tunnel to r.Host through the gateway, or a
+// direct connection when the transport routes r.Host direct. The returned
+// reader holds any bytes the gateway sent after its response.
+func (f *Forwarder) dialTunnel(r *http.Request, transport *http.Transport) (net.Conn, *bufio.Reader, error) {
+	proxyURL, err := transport.Proxy(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx := r.Context()
+	if f.dialer.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.dialer.Timeout)
+		defer cancel()
+	}
+	if proxyURL == nil {
+		// A routing rule sent this host direct.
+		conn, err := f.dialer.DialContext(ctx, "tcp", r.Host)
+		if err != nil {
+			return nil, nil, err
+		}
+		return conn, bufio.NewReader(conn), nil
+	}
+	conn, err := f.dialer.DialContext(ctx, "tcp", proxyURL.Host)
+	if err != nil {
+		return nil, nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	connectReq := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: r.Host},
+		Host:   r.Host,
+		Header: make(http.Header),
+	}
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		credentials := proxyURL.User.Username() + ":" + password
+		connectReq.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+	}
+	if err := connectReq.Write(conn); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, connectReq)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, nil, &tunnelError{status: resp.StatusCode}
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, br, nil
+}
+
+// pipe copies between the client and upstream connections until either
+// side closes, then closes both. Bytes are added to stats as they flow.
+func pipe(client net.Conn, clientReader io.Reader, upstream net.Conn, upstreamReader io.Reader, stats *tenantStats) {
+	var once sync.Once
+	closeBoth := func() {
+		client.Close()
+		upstream.Close()
+	}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		io.Copy(&countingWriter{Writer: upstream, n: stats.sentCounter()}, clientReader)
+		once.Do(closeBoth)
+	}()
+	go func() {
+		defer wg.Done()
+		io.Copy(&countingWriter{Writer: client, n: stats.receivedCounter()}, upstreamReader)
+		once.Do(closeBoth)
+	}()
+	wg.Wait()
+}
+
+// countingWriter adds the number of bytes written to n, if n is set.
+type countingWriter struct {
+	io.Writer
+	n *atomic.Int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	if w.n != nil {
+		w.n.Add(int64(n))
+	}
+	return n, err
+}
+
+// countingReadCloser adds the number of bytes read to n, if n is set.
+type countingReadCloser struct {
+	io.ReadCloser
+	n *atomic.Int64
+}
+
+func (r *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if r.n != nil {
+		r.n.Add(int64(n))
+	}
+	return n, err
+}
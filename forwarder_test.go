@@ -0,0 +1,138 @@
+package proxyhat
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func newTestForwarder(t *testing.T, gw *testGateway, opts ...ForwarderOption) *httptest.Server {
+	t.Helper()
+	opts = append([]ForwarderOption{WithUpstream(WithGateway(gw.gateway()))}, opts...)
+	f, err := NewForwarder(&SubUser{ProxyUsername: "user1"}, "secret", Targeting{Country: "US"}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func forwarderClient(t *testing.T, proxy string, tlsFrom *httptest.Server) *http.Client {
+	t.Helper()
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := &http.Transport{Proxy: http.ProxyURL(proxyURL)}
+	if tlsFrom != nil {
+		transport.TLSClientConfig = tlsFrom.Client().Transport.(*http.Transport).TLSClientConfig
+	}
+	return &http.Client{Transport: transport}
+}
+
+func TestForwarder_HTTP(t *testing.T) {
+	gw := newTestGateway(t, "secret")
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Target", "yes")
+		io.WriteString(w, "plain")
+	}))
+	defer target.Close()
+	fwd := newTestForwarder(t, gw)
+
+	resp, err := forwarderClient(t, fwd.URL, nil).Get(target.URL + "/path")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "plain" || resp.Header.Get("X-Target") != "yes" {
+		t.Errorf("unexpected response: %q %v", body, resp.Header)
+	}
+	if got := gw.seen(); len(got) != 1 || got[0] != "user1-country-us" {
+		t.Errorf("gateway usernames = %v", got)
+	}
+}
+
+func TestForwarder_CONNECT(t *testing.T) {
+	gw := newTestGateway(t, "secret")
+	target := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "tunnelled")
+	}))
+	defer target.Close()
+	fwd := newTestForwarder(t, gw)
+
+	resp, err := forwarderClient(t, fwd.URL, target).Get(target.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "tunnelled" {
+		t.Errorf("body = %q, want %q", body, "tunnelled")
+	}
+	if gw.connectCount() != 1 {
+		t.Errorf("CONNECT count = %d, want 1", gw.connectCount())
+	}
+}
+
+func TestForwarder_CONNECTWithoutDialTimeout(t *testing.T) {
+	gw := newTestGateway(t, "secret")
+	target := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "tunnelled")
+	}))
+	defer target.Close()
+	fwd := newTestForwarder(t, gw, WithUpstream(WithDialTimeout(0)))
+
+	resp, err := forwarderClient(t, fwd.URL, target).Get(target.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
+
+func TestForwarder_GatewayRejectsTunnel(t *testing.T) {
+	gw := newTestGateway(t, "other-password")
+	target := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	fwd := newTestForwarder(t, gw)
+
+	_, err := forwarderClient(t, fwd.URL, target).Get(target.URL)
+	if err == nil {
+		t.Fatal("expected error when the gateway refuses the tunnel")
+	}
+}
+
+func TestForwarder_LocalAuth(t *testing.T) {
+	gw := newTestGateway(t, "secret")
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer target.Close()
+	fwd := newTestForwarder(t, gw, WithLocalAuth("local", "pw"))
+
+	resp, err := forwarderClient(t, fwd.URL, nil).Get(target.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusProxyAuthRequired {
+		t.Errorf("status = %d, want 407", resp.StatusCode)
+	}
+
+	authed, _ := url.Parse(fwd.URL)
+	authed.User = url.UserPassword("local", "pw")
+	resp, err = forwarderClient(t, authed.String(), nil).Get(target.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+	if got := gw.seen(); len(got) != 1 || got[0] != "user1-country-us" {
+		t.Errorf("local credentials leaked to gateway: %v", got)
+	}
+}
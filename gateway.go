@@ -0,0 +1,323 @@
+package proxyhat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultGatewayHost       = "gate.proxyhat.com"
+	DefaultGatewayHTTPPort   = 8080
+	DefaultGatewaySOCKS5Port = 1080
+)
+
+// Gateway describes the ProxyHat proxy gateway that sub-users connect to.
+// Zero fields fall back to the defaults.
+type Gateway struct {
+	Host       string
+	HTTPPort   int
+	SOCKS5Port int
+}
+
+// DefaultGateway returns the public ProxyHat gateway.
+func DefaultGateway() Gateway {
+	return Gateway{
+		Host:       DefaultGatewayHost,
+		HTTPPort:   DefaultGatewayHTTPPort,
+		SOCKS5Port: DefaultGatewaySOCKS5Port,
+	}
+}
+
+func (g Gateway) withDefaults() Gateway {
+	if g.Host == "" {
+		g.Host = DefaultGatewayHost
+	}
+	if g.HTTPPort == 0 {
+		g.HTTPPort = DefaultGatewayHTTPPort
+	}
+	if g.SOCKS5Port == 0 {
+		g.SOCKS5Port = DefaultGatewaySOCKS5Port
+	}
+	return g
+}
+
+// HTTPEndpoint returns the HTTP proxy endpoint for a sub-user with the given targeting.
+func (g Gateway) HTTPEndpoint(subUser *SubUser, password string, t Targeting) (*ProxyEndpoint, error) {
+	g = g.withDefaults()
+	return newProxyEndpoint(g.Host, g.HTTPPort, subUser, password, t)
+}
+
+// SOCKS5Endpoint returns the SOCKS5 proxy endpoint for a sub-user with the given targeting.
+func (g Gateway) SOCKS5Endpoint(subUser *SubUser, password string, t Targeting) (*ProxyEndpoint, error) {
+	g = g.withDefaults()
+	return newProxyEndpoint(g.Host, g.SOCKS5Port, subUser, password, t)
+}
+
+func newProxyEndpoint(host string, port int, subUser *SubUser, password string, t Targeting) (*ProxyEndpoint, error) {
+	if subUser == nil || subUser.ProxyUsername == "" {
+		return nil, errors.New("proxyhat: sub-user has no proxy username")
+	}
+	if password == "" {
+		return nil, errors.New("proxyhat: proxy password is required")
+	}
+	username, err := t.Username(subUser.ProxyUsername)
+	if err != nil {
+		return nil, err
+	}
+	return &ProxyEndpoint{
+		Host:     host,
+		Port:     port,
+		Username: username,
+		Password: password,
+	}, nil
+}
+
+// ProxyEndpoint is a fully resolved gateway address with encoded credentials.
+type ProxyEndpoint struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+}
+
+// Addr returns the endpoint as "host:port".
+func (e *ProxyEndpoint) Addr() string {
+	return net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+}
+
+// URL returns the endpoint as an http:// proxy URL.
+func (e *ProxyEndpoint) URL() *url.URL {
+	return e.urlWithScheme("http")
+}
+
+// SOCKS5URL returns the endpoint as a socks5h:// proxy URL.
+func (e *ProxyEndpoint) SOCKS5URL() *url.URL {
+	return e.urlWithScheme("socks5h")
+}
+
+func (e *ProxyEndpoint) urlWithScheme(scheme string) *url.URL {
+	return &url.URL{
+		Scheme: scheme,
+		User:   url.UserPassword(e.Username, e.Password),
+		Host:   e.Addr(),
+	}
+}
+
+// UserPassHostPort returns the endpoint as "user:pass@host:port".
+func (e *ProxyEndpoint) UserPassHostPort() string {
+	return e.Username + ":" + e.Password + "@" + e.Addr()
+}
+
+// HostPortUserPass returns the endpoint as "host:port:user:pass".
+func (e *ProxyEndpoint) HostPortUserPass() string {
+	return e.Addr() + ":" + e.Username + ":" + e.Password
+}
+
+// Targeting selects the exit node used by the gateway. Empty fields are not
+// encoded, so the zero value routes through any available exit.
+//
+// In JSON, SessionTTL is written as a duration string such as "10m".
+type Targeting struct {
+	Country        string `json:"country,omitempty"`
+	Region         string `json:"region,omitempty"`
+	City           string `json:"city,omitempty"`
+	ISP            string `json:"isp,omitempty"`
+	Zipcode        string `json:"zipcode,omitempty"`
+	ConnectionType string `json:"connection_type,omitempty"`
+
+	// Session keeps the same exit IP for every connection that uses it.
+	Session string `json:"session,omitempty"`
+	// SessionTTL is how long the gateway holds a sticky session. It is
+	// rounded up to whole minutes and requires Session to be set.
+	SessionTTL time.Duration `json:"-"`
+}
+
+type targetingJSON struct {
+	targetingFields
+	SessionTTL string `json:"session_ttl,omitempty"`
+}
+
+// targetingFields drops the Targeting methods so encoding/json does not recurse.
+type targetingFields Targeting
+
+// MarshalJSON implements json.Marshaler.
+func (t Targeting) MarshalJSON() ([]byte, error) {
+	v := targetingJSON{targetingFields: targetingFields(t)}
+	if t.SessionTTL != 0 {
+		v.SessionTTL = t.SessionTTL.String()
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *Targeting) UnmarshalJSON(data []byte) error {
+	var v targetingJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*t = Targeting(v.targetingFields)
+	if v.SessionTTL != "" {
+		d, err := time.ParseDuration(v.SessionTTL)
+		if err != nil {
+			return fmt.Errorf("proxyhat: invalid session_ttl: %w", err)
+		}
+		t.SessionTTL = d
+	}
+	return nil
+}
+
+// WithCountry returns a copy of t targeting the given country code.
+func (t Targeting) WithCountry(code string) Targeting {
+	t.Country = code
+	return t
+}
+
+// WithRegion returns a copy of t targeting the given region code.
+func (t Targeting) WithRegion(code string) Targeting {
+	t.Region = code
+	return t
+}
+
+// WithCity returns a copy of t targeting the given city code.
+func (t Targeting) WithCity(code string) Targeting {
+	t.City = code
+	return t
+}
+
+// WithISP returns a copy of t targeting the given ISP code.
+func (t Targeting) WithISP(code string) Targeting {
+	t.ISP = code
+	return t
+}
+
+// WithZipcode returns a copy of t targeting the given zipcode.
+func (t Targeting) WithZipcode(code string) Targeting {
+	t.Zipcode = code
+	return t
+}
+
+// WithConnectionType returns a copy of t using the given connection type.
+func (t Targeting) WithConnectionType(connectionType string) Targeting {
+	t.ConnectionType = connectionType
+	return t
+}
+
+// WithSession returns a copy of t pinned to a sticky session.
+func (t Targeting) WithSession(id string, ttl time.Duration) Targeting {
+	t.Session = id
+	t.SessionTTL = ttl
+	return t
+}
+
+// Targeting returns targeting for the country.
+func (c Country) Targeting() Targeting {
+	return Targeting{Country: c.Code}
+}
+
+// Targeting returns targeting for the region.
+func (r Region) Targeting() Targeting {
+	return Targeting{Country: r.CountryCode, Region: r.Code}
+}
+
+// Targeting returns targeting for the city.
+func (c City) Targeting() Targeting {
+	t := Targeting{Country: c.CountryCode, City: c.Code}
+	if c.RegionCode != nil {
+		t.Region = *c.RegionCode
+	}
+	return t
+}
+
+// Targeting returns targeting for the ISP.
+func (i ISP) Targeting() Targeting {
+	return Targeting{Country: i.CountryCode, ISP: i.Code}
+}
+
+// Targeting returns targeting for the zipcode.
+func (z Zipcode) Targeting() Targeting {
+	t := Targeting{Country: z.CountryCode, Zipcode: z.Code}
+	if z.CityCode != nil {
+		t.City = *z.CityCode
+	}
+	return t
+}
+
+// Validate reports whether t can be encoded into a gateway username.
+func (t Targeting) Validate() error {
+	_, err := t.flags()
+	return err
+}
+
+// Username encodes t into the gateway username format, e.g.
+// "base-country-us-city-berlin-session-abc123-sesstime-10".
+func (t Targeting) Username(base string) (string, error) {
+	if base == "" {
+		return "", errors.New("proxyhat: proxy username is required")
+	}
+	flags, err := t.flags()
+	if err != nil {
+		return "", err
+	}
+	if len(flags) == 0 {
+		return base, nil
+	}
+	return base + "-" + strings.Join(flags, "-"), nil
+}
+
+func (t Targeting) flags() ([]string, error) {
+	var flags []string
+	fields := []struct {
+		key   string
+		value string
+	}{
+		{"country", t.Country},
+		{"region", t.Region},
+		{"city", t.City},
+		{"isp", t.ISP},
+		{"zip", t.Zipcode},
+		{"type", t.ConnectionType},
+		{"session", t.Session},
+	}
+	for _, f := range fields {
+		if f.value == "" {
+			continue
+		}
+		v, err := normalizeTargetingValue(f.key, f.value)
+		if err != nil {
+			return nil, err
+		}
+		flags = append(flags, f.key, v)
+	}
+
+	if t.SessionTTL < 0 {
+		return nil, errors.New("proxyhat: session TTL must not be negative")
+	}
+	if t.SessionTTL > 0 {
+		if t.Session == "" {
+			return nil, errors.New("proxyhat: session TTL requires a session")
+		}
+		minutes := int((t.SessionTTL + time.Minute - 1) / time.Minute)
+		flags = append(flags, "sesstime", strconv.Itoa(minutes))
+	}
+	return flags, nil
+}
+
+// normalizeTargetingValue lower-cases v and replaces spaces with underscores.
+// Characters that would break the username or URL encoding are rejected.
+func normalizeTargetingValue(key, v string) (string, error) {
+	v = strings.ToLower(strings.TrimSpace(v))
+	v = strings.ReplaceAll(v, " ", "_")
+	for _, r := range v {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' || r == '.' {
+			continue
+		}
+		return "", fmt.Errorf("proxyhat: invalid character %q in %s targeting", r, key)
+	}
+	return v, nil
+}
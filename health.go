@@ -0,0 +1,290 @@
+package proxyhat
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultEchoURL          = "https://api.ipify.org?format=json"
+	DefaultProbeTimeout     = 30 * time.Second
+	DefaultProbeConcurrency = 10
+)
+
+// HealthOption configures a HealthChecker.
+type HealthOption func(*HealthChecker)
+
+// WithEchoURL sets the endpoint that reports the caller's IP address. It may
+// answer with JSON containing an "ip" or "origin" field, or with the bare
+// address as plain text.
+func WithEchoURL(u string) HealthOption {
+	return func(h *HealthChecker) {
+		h.echoURL = u
+	}
+}
+
+// WithProbeTimeout sets the timeout for a single probe.
+func WithProbeTimeout(d time.Duration) HealthOption {
+	return func(h *HealthChecker) {
+		h.timeout = d
+	}
+}
+
+// WithProbeConcurrency sets how many probes ProbeAll runs at once.
+func WithProbeConcurrency(n int) HealthOption {
+	return func(h *HealthChecker) {
+		h.concurrency = n
+	}
+}
+
+// WithProbeGateway sets the options used to reach the ProxyHat gateway.
+func WithProbeGateway(opts ...GatewayOption) HealthOption {
+	return func(h *HealthChecker) {
+		h.gatewayOpts = append(h.gatewayOpts, opts...)
+	}
+}
+
+// HealthChecker probes gateway locations by fetching an IP echo endpoint
+// through them and timing each phase of the request.
+type HealthChecker struct {
+	subUser     *SubUser
+	password    string
+	echoURL     string
+	timeout     time.Duration
+	concurrency int
+	gatewayOpts []GatewayOption
+}
+
+// NewHealthChecker creates a HealthChecker that probes as the given sub-user.
+func NewHealthChecker(subUser *SubUser, password string, opts ...HealthOption) *HealthChecker {
+	h := &HealthChecker{
+		subUser:     subUser,
+		password:    password,
+		echoURL:     DefaultEchoURL,
+		timeout:     DefaultProbeTimeout,
+		concurrency: DefaultProbeConcurrency,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// ProbeResult is the outcome of probing one targeting.
+type ProbeResult struct {
+	Targeting  Targeting `json:"targeting"`
+	StartedAt  time.Time `json:"started_at"`
+	StatusCode int       `json:"status_code,omitempty"`
+	ExitIP     string    `json:"exit_ip,omitempty"`
+	Error      string    `json:"error,omitempty"`
+
+	// Connect is the time to open the TCP connection to the gateway.
+	Connect time.Duration `json:"-"`
+	// TLSHandshake is the time for the TLS handshake with the echo endpoint.
+	TLSHandshake time.Duration `json:"-"`
+	// TimeToFirstByte is measured from the start of the request.
+	TimeToFirstByte time.Duration `json:"-"`
+	Total           time.Duration `json:"-"`
+}
+
+// OK reports whether the probe succeeded.
+func (r *ProbeResult) OK() bool {
+	return r.Error == ""
+}
+
+// MarshalJSON implements json.Marshaler. Durations are written in milliseconds.
+func (r ProbeResult) MarshalJSON() ([]byte, error) {
+	type plain ProbeResult
+	return json.Marshal(struct {
+		plain
+		ConnectMS         float64 `json:"connect_ms"`
+		TLSHandshakeMS    float64 `json:"tls_handshake_ms"`
+		TimeToFirstByteMS float64 `json:"ttfb_ms"`
+		TotalMS           float64 `json:"total_ms"`
+	}{
+		plain:             plain(r),
+		ConnectMS:         milliseconds(r.Connect),
+		TLSHandshakeMS:    milliseconds(r.TLSHandshake),
+		TimeToFirstByteMS: milliseconds(r.TimeToFirstByte),
+		TotalMS:           milliseconds(r.Total),
+	})
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Probe fetches the echo endpoint through the gateway with targeting t.
+// Failures are reported in the result rather than as an error.
+func (h *HealthChecker) Probe(ctx context.Context, t Targeting) ProbeResult {
+	result := ProbeResult{Targeting: t, StartedAt: time.Now()}
+	fail := func(err error) ProbeResult {
+		result.Error = err.Error()
+		result.Total = time.Since(result.StartedAt)
+		return result
+	}
+
+	transport, err := NewProxyTransport(h.subUser, h.password, t, h.gatewayOpts...)
+	if err != nil {
+		return fail(err)
+	}
+	transport.DisableKeepAlives = true
+	defer transport.CloseIdleConnections()
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	// The trace hooks may still run on the transport's goroutines after a
+	// timed-out RoundTrip has returned, so they record under mu and the
+	// timings are copied into result once RoundTrip is done.
+	var (
+		mu                       sync.Mutex
+		connectStart, tlsStart   time.Time
+		connect, handshake, ttfb time.Duration
+	)
+	trace := &httptrace.ClientTrace{
+		ConnectStart: func(_, _ string) {
+			mu.Lock()
+			connectStart = time.Now()
+			mu.Unlock()
+		},
+		ConnectDone: func(_, _ string, err error) {
+			mu.Lock()
+			if err == nil {
+				connect = time.Since(connectStart)
+			}
+			mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			mu.Lock()
+			tlsStart = time.Now()
+			mu.Unlock()
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			mu.Lock()
+			if err == nil {
+				handshake = time.Since(tlsStart)
+			}
+			mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			mu.Lock()
+			ttfb = time.Since(result.StartedAt)
+			mu.Unlock()
+		},
+	}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodGet, h.echoURL, nil)
+	if err != nil {
+		return fail(err)
+	}
+
+	resp, err := transport.RoundTrip(req)
+	mu.Lock()
+	result.Connect, result.TLSHandshake, result.TimeToFirstByte = connect, handshake, ttfb
+	mu.Unlock()
+	if err != nil {
+		return fail(err)
+	}
+	defer resp.Body.Close()
+	result.StatusCode = resp.StatusCode
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return fail(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fail(fmt.Errorf("proxyhat: echo endpoint returned %d %s", resp.StatusCode, http.StatusText(resp.StatusCode)))
+	}
+	ip, err := parseEchoIP(body)
+	if err != nil {
+		return fail(err)
+	}
+	result.ExitIP = ip
+	result.Total = time.Since(result.StartedAt)
+	return result
+}
+
+// ProbeAll probes every targeting concurrently and returns the results in
+// the same order.
+func (h *HealthChecker) ProbeAll(ctx context.Context, targetings []Targeting) ProbeResults {
+	results := make(ProbeResults, len(targetings))
+	concurrency := h.concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := range targetings {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = h.Probe(ctx, targetings[i])
+		}(i)
+	}
+	wg.Wait()
+	return results
+}
+
+func parseEchoIP(body []byte) (string, error) {
+	var fields struct {
+		IP     string `json:"ip"`
+		Origin string `json:"origin"`
+	}
+	candidate := strings.TrimSpace(string(body))
+	if json.Unmarshal(body, &fields) == nil {
+		candidate = fields.IP
+		if candidate == "" {
+			// httpbin reports "origin" and may list several addresses.
+			candidate, _, _ = strings.Cut(fields.Origin, ",")
+		}
+	}
+	candidate = strings.TrimSpace(candidate)
+	if net.ParseIP(candidate) == nil {
+		return "", fmt.Errorf("proxyhat: echo endpoint returned no IP address")
+	}
+	return candidate, nil
+}
+
+// ProbeResults is a list of probe results.
+type ProbeResults []ProbeResult
+
+// WriteJSON writes the results as a JSON array.
+func (rs ProbeResults) WriteJSON(w io.Writer) error {
+	return writeIndentedJSON(w, rs)
+}
+
+// WriteCSV writes the results as CSV with a header row. Durations are in
+// milliseconds.
+func (rs ProbeResults) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{
+		"country", "region", "city", "isp", "zipcode", "connection_type",
+		"started_at", "status_code", "exit_ip",
+		"connect_ms", "tls_handshake_ms", "ttfb_ms", "total_ms", "error",
+	})
+	ms := func(d time.Duration) string {
+		return strconv.FormatFloat(milliseconds(d), 'f', 3, 64)
+	}
+	for _, r := range rs {
+		t := r.Targeting
+		cw.Write([]string{
+			t.Country, t.Region, t.City, t.ISP, t.Zipcode, t.ConnectionType,
+			r.StartedAt.UTC().Format(time.RFC3339), strconv.Itoa(r.StatusCode), r.ExitIP,
+			ms(r.Connect), ms(r.TLSHandshake), ms(r.TimeToFirstByte), ms(r.Total), r.Error,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
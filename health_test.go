@@ -0,0 +1,164 @@
+package proxyhat
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestEchoServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"ip": "203.0.113.7"})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHealthChecker_Probe(t *testing.T) {
+	gw := newTestGateway(t, "secret")
+	echo := newTestEchoServer(t)
+
+	h := NewHealthChecker(&SubUser{ProxyUsername: "user1"}, "secret",
+		WithEchoURL(echo.URL),
+		WithProbeGateway(WithGateway(gw.gateway()), WithTLSConfig(echo.Client().Transport.(*http.Transport).TLSClientConfig)))
+	r := h.Probe(context.Background(), Targeting{Country: "US"})
+	if !r.OK() {
+		t.Fatalf("probe failed: %s", r.Error)
+	}
+	if r.ExitIP != "203.0.113.7" || r.StatusCode != http.StatusOK {
+		t.Errorf("result = %+v", r)
+	}
+	if r.Connect <= 0 || r.TLSHandshake <= 0 || r.TimeToFirstByte <= 0 || r.Total < r.TimeToFirstByte {
+		t.Errorf("timings = connect %v, tls %v, ttfb %v, total %v", r.Connect, r.TLSHandshake, r.TimeToFirstByte, r.Total)
+	}
+	if got := gw.seen(); len(got) != 1 || got[0] != "user1-country-us" {
+		t.Errorf("gateway usernames = %v", got)
+	}
+}
+
+func TestHealthChecker_ProbeAll(t *testing.T) {
+	gw := newTestGateway(t, "secret")
+	echo := newTestEchoServer(t)
+
+	h := NewHealthChecker(&SubUser{ProxyUsername: "user1"}, "secret",
+		WithEchoURL(echo.URL),
+		WithProbeConcurrency(2),
+		WithProbeGateway(WithGateway(gw.gateway()), WithTLSConfig(echo.Client().Transport.(*http.Transport).TLSClientConfig)))
+	targetings := []Targeting{{Country: "US"}, {Country: "DE"}, {Country: "u:s"}}
+	results := h.ProbeAll(context.Background(), targetings)
+	if len(results) != 3 {
+		t.Fatalf("len = %d, want 3", len(results))
+	}
+	for i, r := range results[:2] {
+		if !r.OK() || r.Targeting.Country != targetings[i].Country {
+			t.Errorf("results[%d] = %+v", i, r)
+		}
+	}
+	if results[2].OK() {
+		t.Error("expected invalid targeting to fail")
+	}
+}
+
+func TestHealthChecker_ProbeFailure(t *testing.T) {
+	gw := newTestGateway(t, "secret")
+	h := NewHealthChecker(&SubUser{ProxyUsername: "user1"}, "wrong",
+		WithEchoURL("http://echo.invalid/"),
+		WithProbeGateway(WithGateway(gw.gateway())))
+	r := h.Probe(context.Background(), Targeting{})
+	if r.OK() || r.StatusCode != http.StatusProxyAuthRequired || r.ExitIP != "" {
+		t.Errorf("result = %+v", r)
+	}
+}
+
+func TestHealthChecker_ProbeTimeoutDuringHandshake(t *testing.T) {
+	// The gateway accepts the tunnel but never answers the TLS handshake.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	t.Cleanup(func() {
+		close(stop)
+		ln.Close()
+	})
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if _, err := http.ReadRequest(bufio.NewReader(conn)); err != nil {
+					return
+				}
+				conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+				<-stop
+			}()
+		}
+	}()
+
+	port := ln.Addr().(*net.TCPAddr).Port
+	h := NewHealthChecker(&SubUser{ProxyUsername: "user1"}, "secret",
+		WithEchoURL("https://echo.example/"),
+		WithProbeTimeout(50*time.Millisecond),
+		WithProbeGateway(WithGateway(Gateway{Host: "127.0.0.1", HTTPPort: port})))
+	r := h.Probe(context.Background(), Targeting{})
+	if r.OK() || r.TLSHandshake != 0 {
+		t.Errorf("result = %+v", r)
+	}
+	if r.Connect <= 0 {
+		t.Errorf("Connect = %v, want the tunnel's connect time", r.Connect)
+	}
+}
+
+func TestParseEchoIP(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`{"ip": "203.0.113.7"}`, "203.0.113.7"},
+		{`{"origin": "203.0.113.7, 10.0.0.1"}`, "203.0.113.7"},
+		{"2001:db8::1\n", "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		if got, err := parseEchoIP([]byte(tt.body)); err != nil || got != tt.want {
+			t.Errorf("parseEchoIP(%q) = %q, %v", tt.body, got, err)
+		}
+	}
+	if _, err := parseEchoIP([]byte("<html>")); err == nil {
+		t.Error("expected error for non-IP body")
+	}
+}
+
+func TestProbeResults_Write(t *testing.T) {
+	results := ProbeResults{{Targeting: Targeting{Country: "US"}, ExitIP: "203.0.113.7", StatusCode: 200, Connect: 1500000}}
+
+	var b strings.Builder
+	if err := results.WriteJSON(&b); err != nil {
+		t.Fatal(err)
+	}
+	var out []map[string]any
+	if err := json.Unmarshal([]byte(b.String()), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out[0]["exit_ip"] != "203.0.113.7" || out[0]["connect_ms"] != 1.5 {
+		t.Errorf("JSON = %s", b.String())
+	}
+
+	b.Reset()
+	if err := results.WriteCSV(&b); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "country,") || !strings.Contains(lines[1], "US,") || !strings.Contains(lines[1], ",1.500,") {
+		t.Errorf("CSV = %s", b.String())
+	}
+}
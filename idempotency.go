@@ -0,0 +1,72 @@
+package proxyhat
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+)
+
+// IdempotencyKeyHeader is the header carrying a request's idempotency key.
+// The API performs a mutating request at most once per key and replays
+// the original response to repeats.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+type idempotencyKeyContextKey struct{}
+
+// ContextWithIdempotencyKey sets the idempotency key of calls made with the
+// returned context, for any method. Reuse the same key when repeating a
+// call yourself, e.g. after a crash, so the API does not perform it twice.
+// An empty key turns off the key the client would otherwise generate.
+//
+// Concurrent calls in this process with the same method, path and key are
+// sent once, and every caller receives the result.
+func ContextWithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContextKey{}, key)
+}
+
+// NewIdempotencyKey returns a random key suitable for
+// ContextWithIdempotencyKey.
+func NewIdempotencyKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("proxyhat: failed to generate idempotency key: " + err.Error())
+	}
+	// Format as a version 4 UUID.
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// idempotencyMiddleware sets the Idempotency-Key header. With a retry
+// policy, POST calls get a generated key so that every attempt of the call
+// carries the same one.
+func (c *Client) idempotencyMiddleware() Middleware {
+	var flights flightGroup
+	return func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			key, explicit := req.Context().Value(idempotencyKeyContextKey{}).(string)
+			if !explicit {
+				key = req.Header.Get(IdempotencyKeyHeader)
+				explicit = key != ""
+			}
+			if !explicit && req.Method == http.MethodPost && c.retryPolicy != nil {
+				key = NewIdempotencyKey()
+			}
+			if key == "" {
+				return next(req)
+			}
+
+			req.Header = req.Header.Clone()
+			if req.Header == nil {
+				req.Header = http.Header{}
+			}
+			req.Header.Set(IdempotencyKeyHeader, key)
+			if !explicit || req.raw {
+				// Generated keys are unique, so there is nothing to share.
+				return next(req)
+			}
+			return flights.do(req.Method+" "+req.Path+" "+key, req, next)
+		}
+	}
+}
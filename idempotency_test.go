@@ -0,0 +1,147 @@
+package proxyhat
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestIdempotency_GeneratedKeyReusedAcrossRetries(t *testing.T) {
+	client, mux, _, cleanup := setupRetryTest(RetryPolicy{})
+	defer cleanup()
+
+	var keys []string
+	mux.HandleFunc("/sub-users", func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(IdempotencyKeyHeader))
+		if len(keys) < 3 {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"message": "busy"})
+			return
+		}
+		writePayload(w, SubUser{UUID: "su-1"})
+	})
+
+	ctx := ContextWithRetry(context.Background(), true)
+	if _, err := client.SubUsers.Create(ctx, CreateSubUserParams{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 3 || !uuidPattern.MatchString(keys[0]) || keys[1] != keys[0] || keys[2] != keys[0] {
+		t.Errorf("keys = %q, want the same UUID on every attempt", keys)
+	}
+
+	first := keys[0]
+	if _, err := client.SubUsers.Create(ctx, CreateSubUserParams{}); err != nil {
+		t.Fatal(err)
+	}
+	if next := keys[len(keys)-1]; next == first || !uuidPattern.MatchString(next) {
+		t.Errorf("second call key = %q, want a new UUID", next)
+	}
+}
+
+func TestIdempotency_Headers(t *testing.T) {
+	var got atomic.Value
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		got.Store(r.Header.Get(IdempotencyKeyHeader))
+		writePayload(w, SubUser{})
+	}
+
+	tests := []struct {
+		name  string
+		opts  []Option
+		ctx   context.Context
+		match func(string) bool
+	}{
+		{"no retries", nil, context.Background(), func(k string) bool { return k == "" }},
+		{"retries", []Option{WithRetryPolicy(RetryPolicy{})}, context.Background(), uuidPattern.MatchString},
+		{"explicit", nil, ContextWithIdempotencyKey(context.Background(), "order-42"), func(k string) bool { return k == "order-42" }},
+		{"disabled", []Option{WithRetryPolicy(RetryPolicy{})}, ContextWithIdempotencyKey(context.Background(), ""), func(k string) bool { return k == "" }},
+	}
+	for _, tt := range tests {
+		client, mux, cleanup := setupTest(tt.opts...)
+		mux.HandleFunc("/sub-users", handler)
+		if _, err := client.SubUsers.Create(tt.ctx, CreateSubUserParams{}); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if key := got.Load().(string); !tt.match(key) {
+			t.Errorf("%s: %s = %q", tt.name, IdempotencyKeyHeader, key)
+		}
+		cleanup()
+	}
+}
+
+func TestIdempotency_ConcurrentDuplicates(t *testing.T) {
+	client, mux, cleanup := setupTest()
+	defer cleanup()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	mux.HandleFunc("/sub-users", func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		writePayload(w, SubUser{UUID: "su-1"})
+	})
+
+	ctx := ContextWithIdempotencyKey(context.Background(), "create-su-1")
+	const n = 5
+	var wg sync.WaitGroup
+	results := make([]*SubUser, n)
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = client.SubUsers.Create(ctx, CreateSubUserParams{})
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("calls = %d, want 1", calls.Load())
+	}
+	for i := 0; i < n; i++ {
+		if errs[i] != nil || results[i] == nil || results[i].UUID != "su-1" {
+			t.Errorf("caller %d: %+v, %v", i, results[i], errs[i])
+		}
+	}
+	if results[0] == results[1] {
+		t.Error("callers share a result value")
+	}
+}
+
+func TestIdempotency_CallerCanceledIndependently(t *testing.T) {
+	client, mux, cleanup := setupTest()
+	defer cleanup()
+
+	release := make(chan struct{})
+	mux.HandleFunc("/sub-users", func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		writePayload(w, SubUser{UUID: "su-1"})
+	})
+
+	ctx := ContextWithIdempotencyKey(context.Background(), "k")
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.SubUsers.Create(ctx, CreateSubUserParams{})
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := client.SubUsers.Create(canceled, CreateSubUserParams{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled caller err = %v", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("other caller err = %v", err)
+	}
+}
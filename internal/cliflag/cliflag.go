@@ -0,0 +1,24 @@
+// Package cliflag parses the command-line flags shared by the proxyhat
+// commands.
+package cliflag
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	proxyhat "github.com/ProxyHatCom/go-sdk"
+)
+
+// ParseGateway parses a -gateway flag value of the form host:port.
+func ParseGateway(s string) (proxyhat.Gateway, error) {
+	host, portStr, err := net.SplitHostPort(s)
+	if err != nil {
+		return proxyhat.Gateway{}, fmt.Errorf("invalid -gateway %q: %w", s, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return proxyhat.Gateway{}, fmt.Errorf("invalid -gateway port %q", portStr)
+	}
+	return proxyhat.Gateway{Host: host, HTTPPort: port}, nil
+}
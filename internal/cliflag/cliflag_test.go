@@ -0,0 +1,32 @@
+package cliflag
+
+import (
+	"testing"
+
+	proxyhat "github.com/ProxyHatCom/go-sdk"
+)
+
+func TestParseGateway(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    proxyhat.Gateway
+		wantErr bool
+	}{
+		{in: "gate.proxyhat.com:8080", want: proxyhat.Gateway{Host: "gate.proxyhat.com", HTTPPort: 8080}},
+		{in: "127.0.0.1:3128", want: proxyhat.Gateway{Host: "127.0.0.1", HTTPPort: 3128}},
+		{in: "[::1]:8080", want: proxyhat.Gateway{Host: "::1", HTTPPort: 8080}},
+		{in: "gate.proxyhat.com", wantErr: true},
+		{in: "gate.proxyhat.com:http", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseGateway(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseGateway(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseGateway(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -42,7 +42,7 @@ func (p *LocationParams) values() url.Values {
 // Countries returns the list of available countries.
 func (s *LocationsService) Countries(ctx context.Context, params *LocationParams) ([]Country, error) {
 	var result []Country
-	err := s.client.doRequestWithParams(ctx, "GET", "locations/countries", params.values(), &result)
+	err := s.client.doRequestWithParams(ctx, "Locations.Countries", "GET", "locations/countries", params.values(), &result)
 	if err != nil {
 		return nil, err
 	}
@@ -69,7 +69,7 @@ func (s *LocationsService) Regions(ctx context.Context, params *RegionParams) ([
 		params = &RegionParams{}
 	}
 	var result []Region
-	err := s.client.doRequestWithParams(ctx, "GET", "locations/regions", params.values(), &result)
+	err := s.client.doRequestWithParams(ctx, "Locations.Regions", "GET", "locations/regions", params.values(), &result)
 	if err != nil {
 		return nil, err
 	}
@@ -96,7 +96,7 @@ func (s *LocationsService) Cities(ctx context.Context, params *CityParams) ([]Ci
 		params = &CityParams{}
 	}
 	var result []City
-	err := s.client.doRequestWithParams(ctx, "GET", "locations/cities", params.values(), &result)
+	err := s.client.doRequestWithParams(ctx, "Locations.Cities", "GET", "locations/cities", params.values(), &result)
 	if err != nil {
 		return nil, err
 	}
@@ -109,7 +109,7 @@ func (s *LocationsService) ISPs(ctx context.Context, params *RegionParams) ([]IS
 		params = &RegionParams{}
 	}
 	var result []ISP
-	err := s.client.doRequestWithParams(ctx, "GET", "locations/isps", params.values(), &result)
+	err := s.client.doRequestWithParams(ctx, "Locations.ISPs", "GET", "locations/isps", params.values(), &result)
 	if err != nil {
 		return nil, err
 	}
@@ -140,7 +140,7 @@ func (s *LocationsService) Zipcodes(ctx context.Context, params *ZipcodeParams)
 		params = &ZipcodeParams{}
 	}
 	var result []Zipcode
-	err := s.client.doRequestWithParams(ctx, "GET", "locations/zipcodes", params.values(), &result)
+	err := s.client.doRequestWithParams(ctx, "Locations.Zipcodes", "GET", "locations/zipcodes", params.values(), &result)
 	if err != nil {
 		return nil, err
 	}
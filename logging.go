@@ -0,0 +1,77 @@
+package proxyhat
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// WithLogger logs every API call to l: method, path, status, latency,
+// attempts, request ID and response size. Successful calls are logged at
+// Info and failures at Error. When l is enabled for Debug, the request body
+// and decoded response are included after passing through Redact.
+func WithLogger(l *slog.Logger) Option {
+	return func(c *Client) {
+		c.logger = l
+	}
+}
+
+func loggingMiddleware(l *slog.Logger) Middleware {
+	return func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			start := time.Now()
+			resp, err := next(req)
+			latency := time.Since(start)
+
+			ctx := req.Context()
+			level := slog.LevelInfo
+			if err != nil {
+				level = slog.LevelError
+			}
+			if !l.Enabled(ctx, level) {
+				return resp, err
+			}
+
+			attrs := []slog.Attr{
+				slog.String("method", req.Method),
+				slog.String("path", req.Path),
+				slog.Duration("latency", latency),
+				slog.Int("attempts", attempts(resp, err)),
+			}
+			if resp != nil && resp.Response != nil {
+				attrs = append(attrs, slog.Int("status", resp.StatusCode))
+				if id := resp.Header.Get("X-Request-Id"); id != "" {
+					attrs = append(attrs, slog.String("request_id", id))
+				}
+				if resp.size >= 0 {
+					attrs = append(attrs, slog.Int64("response_size", resp.size))
+				}
+			}
+			if err != nil {
+				attrs = append(attrs, slog.String("error", err.Error()))
+			}
+			if l.Enabled(ctx, slog.LevelDebug) {
+				if req.Body != nil {
+					attrs = append(attrs, slog.Any("request_body", Redact(req.Body)))
+				}
+				if err == nil && req.Result != nil {
+					attrs = append(attrs, slog.Any("response_body", Redact(req.Result)))
+				}
+			}
+			l.LogAttrs(ctx, level, "proxyhat api call", attrs...)
+			return resp, err
+		}
+	}
+}
+
+// attempts returns how many times a call was sent.
+func attempts(resp *Response, err error) int {
+	if resp != nil && resp.Attempts > 0 {
+		return resp.Attempts
+	}
+	var re *RetryError
+	if errors.As(err, &re) {
+		return len(re.Attempts)
+	}
+	return 1
+}
@@ -0,0 +1,135 @@
+package proxyhat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	client, mux, cleanup := setupTest(WithLogger(logger), WithRetryPolicy(RetryPolicy{BaseDelay: time.Millisecond}))
+	defer cleanup()
+
+	var calls int
+	mux.HandleFunc("/sub-users", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"message": "busy"})
+			return
+		}
+		w.Header().Set("X-Request-Id", "req-123")
+		writePayload(w, SubUser{ProxyUsername: "user1"})
+	})
+
+	ctx := ContextWithRetry(context.Background(), true)
+	if _, err := client.SubUsers.Create(ctx, CreateSubUserParams{ProxyPassword: "hunter2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(buf.String(), "hunter2") || strings.Contains(buf.String(), "test-api-key") {
+		t.Errorf("secret leaked: %s", buf.String())
+	}
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"level":      "INFO",
+		"method":     "POST",
+		"path":       "sub-users",
+		"status":     float64(200),
+		"attempts":   float64(2),
+		"request_id": "req-123",
+	}
+	for k, v := range want {
+		if entry[k] != v {
+			t.Errorf("%s = %v, want %v", k, entry[k], v)
+		}
+	}
+	if entry["response_size"] == nil || entry["latency"] == nil {
+		t.Errorf("missing response_size or latency: %v", entry)
+	}
+	if body, _ := entry["request_body"].(map[string]any); body["proxy_password"] != Redacted {
+		t.Errorf("request_body = %v", entry["request_body"])
+	}
+	if body, _ := entry["response_body"].(map[string]any); body["proxy_username"] != "user1" {
+		t.Errorf("response_body = %v", entry["response_body"])
+	}
+}
+
+func TestWithLogger_RedactsTwoFactorBodies(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	client, mux, cleanup := setupTest(WithLogger(logger))
+	defer cleanup()
+
+	const totp = "JBSWY3DPEHPK3PXP"
+	mux.HandleFunc("/profile/2fa/enable", func(w http.ResponseWriter, r *http.Request) {
+		writePayload(w, TwoFactorEnableResponse{
+			QR:            "otpauth://totp/ProxyHat:a@example.com?secret=" + totp + "&issuer=ProxyHat",
+			Secret:        totp,
+			RecoveryCodes: []string{"rc-hunter2"},
+		})
+	})
+	mux.HandleFunc("/profile/2fa/confirm", func(w http.ResponseWriter, r *http.Request) {
+		writePayload(w, map[string]bool{"confirmed": true})
+	})
+	mux.HandleFunc("/profile/password", func(w http.ResponseWriter, r *http.Request) {
+		writePayload(w, map[string]bool{"changed": true})
+	})
+
+	ctx := context.Background()
+	if _, err := client.TwoFactor.Enable(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.TwoFactor.Confirm(ctx, "493817"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := client.TwoFactor.ChangePassword(ctx, ChangePasswordParams{
+		CurrentPassword:      "old-hunter2",
+		Password:             "new-hunter2",
+		PasswordConfirmation: "new-hunter2",
+		TwofaCode:            String("271828"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, secret := range []string{totp, "rc-hunter2", "493817", "old-hunter2", "new-hunter2", "271828"} {
+		if strings.Contains(buf.String(), secret) {
+			t.Errorf("secret %q leaked: %s", secret, buf.String())
+		}
+	}
+	if !strings.Contains(buf.String(), `"confirmed":true`) {
+		t.Errorf("non-secret fields were redacted too: %s", buf.String())
+	}
+}
+
+func TestWithLogger_Error(t *testing.T) {
+	var buf bytes.Buffer
+	client, mux, cleanup := setupTest(WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))))
+	defer cleanup()
+	mux.HandleFunc("/sub-users/missing", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "not found"})
+	})
+
+	client.SubUsers.Get(context.Background(), "missing")
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["level"] != "ERROR" || entry["status"] != float64(404) || entry["error"] != "proxyhat: 404 not found" {
+		t.Errorf("entry = %v", entry)
+	}
+	if _, ok := entry["response_body"]; ok {
+		t.Error("bodies should only be logged at debug level")
+	}
+}
@@ -0,0 +1,292 @@
+package proxyhat
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RequestMetrics describes one completed API call.
+type RequestMetrics struct {
+	Method string
+	// Endpoint is the request path with UUID and numeric segments replaced
+	// by ":id", e.g. "sub-users/:id", to keep label cardinality bounded.
+	Endpoint string
+	// StatusCode is zero when no response was received.
+	StatusCode int
+	Err        error
+	Duration   time.Duration
+	// Attempts is how many times the request was sent, including retries.
+	Attempts      int
+	BytesSent     int64
+	BytesReceived int64
+}
+
+// StatusClass returns "2xx" through "5xx" for the response status. Without
+// a response it returns "network" for transport failures and "client" for
+// errors raised before the request was sent, such as ErrCircuitOpen or a
+// rate limiter wait that could not be met.
+func (m RequestMetrics) StatusClass() string {
+	if m.StatusCode == 0 {
+		var te *TransportError
+		if m.Err != nil && !errors.As(m.Err, &te) {
+			return "client"
+		}
+		return "network"
+	}
+	return strconv.Itoa(m.StatusCode/100) + "xx"
+}
+
+// MetricsCollector receives metrics for every API call.
+// Implementations must be safe for concurrent use.
+type MetricsCollector interface {
+	ObserveRequest(m RequestMetrics)
+}
+
+// WithMetrics reports every API call to mc.
+func WithMetrics(mc MetricsCollector) Option {
+	return func(c *Client) {
+		c.metrics = mc
+	}
+}
+
+func metricsMiddleware(mc MetricsCollector) Middleware {
+	return func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			start := time.Now()
+			resp, err := next(req)
+			m := RequestMetrics{
+				Method:   req.Method,
+				Endpoint: metricsEndpoint(req.Path),
+				Err:      err,
+				Duration: time.Since(start),
+				Attempts: attempts(resp, err),
+			}
+			if resp != nil && resp.Response != nil {
+				m.StatusCode = resp.StatusCode
+				m.BytesSent = resp.sent
+				m.BytesReceived = max(resp.size, 0)
+			} else {
+				var apiErr *Error
+				if errors.As(err, &apiErr) {
+					m.StatusCode = apiErr.StatusCode
+				}
+			}
+			mc.ObserveRequest(m)
+			return resp, err
+		}
+	}
+}
+
+// metricsEndpoint replaces UUID and all-digit path segments with ":id".
+func metricsEndpoint(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, seg := range segments {
+		if uuidShape.MatchString(seg) || isDigits(seg) {
+			segments[i] = ":id"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// DefaultMetricsBuckets are the latency histogram bounds in seconds.
+var DefaultMetricsBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// InMemoryMetrics is a MetricsCollector that keeps counters and latency
+// histograms in memory and serves them in the Prometheus text exposition
+// format, or OpenMetrics when the scraper asks for it.
+type InMemoryMetrics struct {
+	buckets []float64
+
+	mu        sync.Mutex
+	endpoints map[endpointKey]*endpointMetrics
+}
+
+type endpointKey struct {
+	method   string
+	endpoint string
+}
+
+type endpointMetrics struct {
+	requests map[string]uint64 // by status class
+	retries  uint64
+	sent     int64
+	received int64
+	// buckets holds non-cumulative counts; the last one is +Inf.
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+// NewInMemoryMetrics creates an InMemoryMetrics with the given histogram
+// bounds in seconds, or DefaultMetricsBuckets if none are given.
+func NewInMemoryMetrics(buckets ...float64) *InMemoryMetrics {
+	if len(buckets) == 0 {
+		buckets = DefaultMetricsBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &InMemoryMetrics{buckets: buckets, endpoints: map[endpointKey]*endpointMetrics{}}
+}
+
+// ObserveRequest implements MetricsCollector.
+func (m *InMemoryMetrics) ObserveRequest(r RequestMetrics) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := endpointKey{r.Method, r.Endpoint}
+	e := m.endpoints[key]
+	if e == nil {
+		e = &endpointMetrics{requests: map[string]uint64{}, buckets: make([]uint64, len(m.buckets)+1)}
+		m.endpoints[key] = e
+	}
+	e.requests[r.StatusClass()]++
+	if r.Attempts > 1 {
+		e.retries += uint64(r.Attempts - 1)
+	}
+	e.sent += r.BytesSent
+	e.received += r.BytesReceived
+	secs := r.Duration.Seconds()
+	e.buckets[sort.SearchFloat64s(m.buckets, secs)]++
+	e.sum += secs
+	e.count++
+}
+
+const (
+	prometheusContentType  = "text/plain; version=0.0.4; charset=utf-8"
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// ServeHTTP serves the metrics for scraping.
+func (m *InMemoryMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	openMetrics := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+	if openMetrics {
+		w.Header().Set("Content-Type", openMetricsContentType)
+	} else {
+		w.Header().Set("Content-Type", prometheusContentType)
+	}
+	m.WriteText(w, openMetrics)
+}
+
+// WriteText writes the metrics in the Prometheus text format, or in the
+// OpenMetrics format if openMetrics is set.
+func (m *InMemoryMetrics) WriteText(w io.Writer, openMetrics bool) error {
+	m.mu.Lock()
+	keys := make([]endpointKey, 0, len(m.endpoints))
+	for k := range m.endpoints {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].endpoint != keys[j].endpoint {
+			return keys[i].endpoint < keys[j].endpoint
+		}
+		return keys[i].method < keys[j].method
+	})
+
+	var b strings.Builder
+	family := func(name, typ, help string) {
+		if openMetrics && typ == "counter" {
+			name = strings.TrimSuffix(name, "_total")
+		}
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+	labels := func(k endpointKey, extra ...string) string {
+		pairs := []string{"method", k.method, "endpoint", k.endpoint}
+		pairs = append(pairs, extra...)
+		parts := make([]string, 0, len(pairs)/2)
+		for i := 0; i < len(pairs); i += 2 {
+			parts = append(parts, pairs[i]+`="`+escapeLabel(pairs[i+1])+`"`)
+		}
+		return "{" + strings.Join(parts, ",") + "}"
+	}
+
+	family("proxyhat_requests_total", "counter", "API requests by status class.")
+	for _, k := range keys {
+		e := m.endpoints[k]
+		for _, class := range sortedKeys(e.requests) {
+			fmt.Fprintf(&b, "proxyhat_requests_total%s %d\n", labels(k, "class", class), e.requests[class])
+		}
+	}
+	family("proxyhat_request_errors_total", "counter", "Failed API requests by status class.")
+	for _, k := range keys {
+		e := m.endpoints[k]
+		for _, class := range sortedKeys(e.requests) {
+			if class == "2xx" || class == "3xx" {
+				continue
+			}
+			fmt.Fprintf(&b, "proxyhat_request_errors_total%s %d\n", labels(k, "class", class), e.requests[class])
+		}
+	}
+	family("proxyhat_retries_total", "counter", "API request retries.")
+	for _, k := range keys {
+		fmt.Fprintf(&b, "proxyhat_retries_total%s %d\n", labels(k), m.endpoints[k].retries)
+	}
+	family("proxyhat_request_bytes_total", "counter", "API request body bytes sent.")
+	for _, k := range keys {
+		fmt.Fprintf(&b, "proxyhat_request_bytes_total%s %d\n", labels(k), m.endpoints[k].sent)
+	}
+	family("proxyhat_response_bytes_total", "counter", "API response body bytes received.")
+	for _, k := range keys {
+		fmt.Fprintf(&b, "proxyhat_response_bytes_total%s %d\n", labels(k), m.endpoints[k].received)
+	}
+	family("proxyhat_request_duration_seconds", "histogram", "API request latency, including retries.")
+	for _, k := range keys {
+		e := m.endpoints[k]
+		var cumulative uint64
+		for i, n := range e.buckets {
+			cumulative += n
+			le := "+Inf"
+			if i < len(m.buckets) {
+				le = formatFloat(m.buckets[i])
+			}
+			fmt.Fprintf(&b, "proxyhat_request_duration_seconds_bucket%s %d\n", labels(k, "le", le), cumulative)
+		}
+		fmt.Fprintf(&b, "proxyhat_request_duration_seconds_sum%s %s\n", labels(k), formatFloat(e.sum))
+		fmt.Fprintf(&b, "proxyhat_request_duration_seconds_count%s %d\n", labels(k), e.count)
+	}
+	m.mu.Unlock()
+
+	if openMetrics {
+		b.WriteString("# EOF\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func sortedKeys(m map[string]uint64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,136 @@
+package proxyhat
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithMetrics(t *testing.T) {
+	metrics := NewInMemoryMetrics(0.1, 1)
+	client, mux, cleanup := setupTest(WithMetrics(metrics), WithRetryPolicy(RetryPolicy{BaseDelay: time.Millisecond}))
+	defer cleanup()
+
+	var calls int
+	mux.HandleFunc("/sub-users/0b7e9a4c-1111-2222-3333-444455556666", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			writeJSON(w, http.StatusBadGateway, map[string]string{"message": "bad gateway"})
+			return
+		}
+		writePayload(w, SubUser{ProxyUsername: "user1"})
+	})
+	mux.HandleFunc("/sub-users/missing", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "not found"})
+	})
+
+	if _, err := client.SubUsers.Get(context.Background(), "0b7e9a4c-1111-2222-3333-444455556666"); err != nil {
+		t.Fatal(err)
+	}
+	client.SubUsers.Get(context.Background(), "missing")
+
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != prometheusContentType {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	out := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE proxyhat_requests_total counter\n",
+		`proxyhat_requests_total{method="GET",endpoint="sub-users/:id",class="2xx"} 1`,
+		`proxyhat_requests_total{method="GET",endpoint="sub-users/missing",class="4xx"} 1`,
+		`proxyhat_request_errors_total{method="GET",endpoint="sub-users/missing",class="4xx"} 1`,
+		`proxyhat_retries_total{method="GET",endpoint="sub-users/:id"} 1`,
+		`proxyhat_request_duration_seconds_bucket{method="GET",endpoint="sub-users/:id",le="+Inf"} 1`,
+		`proxyhat_request_duration_seconds_count{method="GET",endpoint="sub-users/missing"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `proxyhat_request_errors_total{method="GET",endpoint="sub-users/:id"`) {
+		t.Errorf("successful call counted as error:\n%s", out)
+	}
+	if !strings.Contains(out, `proxyhat_response_bytes_total{method="GET",endpoint="sub-users/:id"} `) ||
+		strings.Contains(out, `proxyhat_response_bytes_total{method="GET",endpoint="sub-users/:id"} 0`) {
+		t.Errorf("response bytes not recorded:\n%s", out)
+	}
+}
+
+func TestInMemoryMetrics_Histogram(t *testing.T) {
+	m := NewInMemoryMetrics(0.1, 1)
+	for _, d := range []time.Duration{50 * time.Millisecond, 100 * time.Millisecond, 500 * time.Millisecond, 2 * time.Second} {
+		m.ObserveRequest(RequestMetrics{Method: "GET", Endpoint: "plans", StatusCode: 200, Duration: d, Attempts: 1})
+	}
+	var b strings.Builder
+	if err := m.WriteText(&b, false); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`proxyhat_request_duration_seconds_bucket{method="GET",endpoint="plans",le="0.1"} 2`,
+		`proxyhat_request_duration_seconds_bucket{method="GET",endpoint="plans",le="1"} 3`,
+		`proxyhat_request_duration_seconds_bucket{method="GET",endpoint="plans",le="+Inf"} 4`,
+		`proxyhat_request_duration_seconds_sum{method="GET",endpoint="plans"} 2.65`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("missing %q in:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestInMemoryMetrics_OpenMetrics(t *testing.T) {
+	m := NewInMemoryMetrics()
+	m.ObserveRequest(RequestMetrics{Method: "POST", Endpoint: "auth/login", Duration: time.Second, Attempts: 1})
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	out := rec.Body.String()
+	if rec.Header().Get("Content-Type") != openMetricsContentType || !strings.HasSuffix(out, "# EOF\n") {
+		t.Errorf("unexpected OpenMetrics output:\n%s", out)
+	}
+	if !strings.Contains(out, "# TYPE proxyhat_requests counter\n") ||
+		!strings.Contains(out, `proxyhat_requests_total{method="POST",endpoint="auth/login",class="network"} 1`) {
+		t.Errorf("unexpected counter family:\n%s", out)
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	tests := map[string]string{
+		"sub-users": "sub-users",
+		"/sub-users/0b7e9a4c-1111-2222-3333-444455556666/":      "sub-users/:id",
+		"payments/8F6A1B2C-0D3E-4F5A-8B9C-0D1E2F3A4B5C/invoice": "payments/:id/invoice",
+		"plans/regular/starter":                                 "plans/regular/starter",
+		"profile/api-keys/42":                                   "profile/api-keys/:id",
+		"profile/2fa/status":                                    "profile/2fa/status",
+		"locations/ipv4-ranges":                                 "locations/ipv4-ranges",
+	}
+	for in, want := range tests {
+		if got := metricsEndpoint(in); got != want {
+			t.Errorf("metricsEndpoint(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRequestMetrics_StatusClass(t *testing.T) {
+	tests := []struct {
+		m    RequestMetrics
+		want string
+	}{
+		{RequestMetrics{StatusCode: 204}, "2xx"},
+		{RequestMetrics{StatusCode: 503, Err: &Error{StatusCode: 503}}, "5xx"},
+		{RequestMetrics{Err: &TransportError{Err: io.ErrUnexpectedEOF}}, "network"},
+		{RequestMetrics{Err: &CircuitOpenError{Group: "sub-users"}}, "client"},
+		{RequestMetrics{Err: &ArgumentError{Name: "id"}}, "client"},
+	}
+	for _, tt := range tests {
+		if got := tt.m.StatusClass(); got != tt.want {
+			t.Errorf("StatusClass(%v) = %q, want %q", tt.m.Err, got, tt.want)
+		}
+	}
+}
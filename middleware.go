@@ -0,0 +1,125 @@
+package proxyhat
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Request describes an API call as it passes through the middleware chain.
+// Middleware may change any field before calling the next handler.
+type Request struct {
+	Method string
+	// Path is relative to the client's base URL, e.g. "sub-users/123".
+	Path   string
+	Params url.Values
+	// Body is encoded as the JSON request body unless nil.
+	Body any
+	// Result receives the decoded response payload unless nil.
+	Result any
+	// Header holds headers added to the HTTP request, overriding the
+	// client's defaults.
+	Header http.Header
+	// Operation names the SDK method making the call, e.g.
+	// "SubUsers.Create". It is used as the span name when tracing.
+	Operation string
+
+	ctx context.Context
+	// raw requests accept any content type and leave the response body
+	// unread for the caller.
+	raw bool
+}
+
+// Context returns the request's context.
+func (r *Request) Context() context.Context {
+	if r.ctx != nil {
+		return r.ctx
+	}
+	return context.Background()
+}
+
+// WithContext returns a shallow copy of r with its context changed to ctx.
+func (r *Request) WithContext(ctx context.Context) *Request {
+	r2 := *r
+	r2.ctx = ctx
+	return &r2
+}
+
+// Response wraps the HTTP response of an API call. Its body has already
+// been read and decoded into Request.Result, except for raw downloads such
+// as PaymentsService.Invoice.
+type Response struct {
+	*http.Response
+
+	// Attempts is how many times the request was sent, including retries.
+	// It is zero for responses served from the cache.
+	Attempts int
+	// Cached reports whether the response was served from the cache set
+	// with WithCache without contacting the API.
+	Cached bool
+
+	// size is the response body size, or -1 if unknown.
+	size int64
+	// sent is the request body size.
+	sent int64
+	// received is when the final response arrived.
+	received time.Time
+	// payload is the unwrapped payload as the API sent it, from which
+	// shared calls and the cache decode each caller's Result.
+	payload json.RawMessage
+}
+
+// Handler performs an API call. On API errors it returns the response
+// together with the error.
+type Handler func(req *Request) (*Response, error)
+
+// Middleware wraps a Handler with extra behavior such as logging, metrics
+// or canned responses. A middleware that answers without calling next
+// should fill in req.Result itself.
+type Middleware func(next Handler) Handler
+
+// WithMiddleware adds middleware around every API call. The first
+// middleware added is the outermost. Retries and rate limiting happen
+// inside the chain, so middleware sees each call once.
+func WithMiddleware(mw ...Middleware) Option {
+	return func(c *Client) {
+		c.middleware = append(c.middleware, mw...)
+	}
+}
+
+func chain(mw []Middleware, h Handler) Handler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
+
+// builtinMiddleware returns the middleware for features enabled through
+// options. It runs outside user middleware.
+func (c *Client) builtinMiddleware() []Middleware {
+	var mw []Middleware
+	if c.cache != nil && c.cache.store != nil {
+		// Outermost, so that cache hits are not reported as API calls.
+		c.cache.namespace = cacheNamespace(c.baseURL, c.apiKey)
+		c.cache.logger = c.logger
+		mw = append(mw, c.cache.middleware())
+	}
+	if c.coalesce {
+		mw = append(mw, coalescingMiddleware())
+	}
+	if c.tracer != nil {
+		mw = append(mw, tracingMiddleware(c.tracer))
+	}
+	if c.logger != nil {
+		mw = append(mw, loggingMiddleware(c.logger))
+	}
+	if c.metrics != nil {
+		mw = append(mw, metricsMiddleware(c.metrics))
+	}
+	if c.breaker != nil {
+		mw = append(mw, c.breaker.middleware())
+	}
+	return append(mw, c.idempotencyMiddleware())
+}
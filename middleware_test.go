@@ -0,0 +1,150 @@
+package proxyhat
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestMiddleware_Order(t *testing.T) {
+	var order []string
+	record := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(req *Request) (*Response, error) {
+				order = append(order, name+" "+req.Method+" "+req.Path)
+				resp, err := next(req)
+				order = append(order, name+" done")
+				return resp, err
+			}
+		}
+	}
+	client, mux, cleanup := setupTest(WithMiddleware(record("outer"), record("inner")))
+	defer cleanup()
+	mux.HandleFunc("/sub-users", func(w http.ResponseWriter, r *http.Request) {
+		writePayload(w, []SubUser{})
+	})
+
+	if _, err := client.SubUsers.List(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"outer GET sub-users", "inner GET sub-users", "inner done", "outer done"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+}
+
+func TestMiddleware_ModifiesRequest(t *testing.T) {
+	client, mux, cleanup := setupTest(WithMiddleware(func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			if req.Params.Get("name") != "united" {
+				t.Errorf("params = %v", req.Params)
+			}
+			req.Header = http.Header{"Authorization": {"Bearer other-key"}, "X-Team": {"scraping"}}
+			return next(req)
+		}
+	}))
+	defer cleanup()
+	mux.HandleFunc("/locations/countries", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer other-key" || r.Header.Get("X-Team") != "scraping" {
+			t.Errorf("headers = %v", r.Header)
+		}
+		writeData(w, []Country{{Code: "US"}})
+	})
+
+	countries, err := client.Locations.Countries(context.Background(), &LocationParams{Name: String("united")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(countries) != 1 || countries[0].Code != "US" {
+		t.Errorf("countries = %+v", countries)
+	}
+}
+
+func TestMiddleware_CannedResponse(t *testing.T) {
+	client, _, cleanup := setupTest(WithMiddleware(func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			if req.Method == http.MethodPost && req.Path == "sub-users" {
+				params := req.Body.(CreateSubUserParams)
+				*req.Result.(*SubUser) = SubUser{ProxyUsername: "fake-" + *params.Name}
+				return &Response{Response: &http.Response{StatusCode: http.StatusCreated}}, nil
+			}
+			return next(req)
+		}
+	}))
+	defer cleanup()
+
+	su, err := client.SubUsers.Create(context.Background(), CreateSubUserParams{Name: String("bot")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if su.ProxyUsername != "fake-bot" {
+		t.Errorf("ProxyUsername = %q", su.ProxyUsername)
+	}
+}
+
+func TestMiddleware_SeesErrorsAndRawRequests(t *testing.T) {
+	var statuses []int
+	client, mux, cleanup := setupTest(WithMiddleware(func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			resp, err := next(req)
+			if resp != nil {
+				statuses = append(statuses, resp.StatusCode)
+			}
+			return resp, err
+		}
+	}))
+	defer cleanup()
+	mux.HandleFunc("/payments/pay-1/invoice", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("%PDF"))
+	})
+	mux.HandleFunc("/sub-users/missing", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "not found"})
+	})
+
+	resp, err := client.Payments.Invoice(context.Background(), "pay-1", "pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "%PDF" {
+		t.Errorf("body = %q", body)
+	}
+	if _, err := client.SubUsers.Get(context.Background(), "missing"); !IsNotFoundError(err) {
+		t.Errorf("err = %v, want not found", err)
+	}
+	if !reflect.DeepEqual(statuses, []int{http.StatusOK, http.StatusNotFound}) {
+		t.Errorf("statuses = %v", statuses)
+	}
+}
+
+func TestMiddleware_Operation(t *testing.T) {
+	var ops []string
+	client, mux, cleanup := setupTest(WithMiddleware(func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			ops = append(ops, req.Operation)
+			return next(req)
+		}
+	}))
+	defer cleanup()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writePayload(w, map[string]any{})
+	})
+
+	ctx := context.Background()
+	client.SubUsers.Get(ctx, "su-1")
+	client.Locations.Countries(ctx, nil)
+	client.TwoFactor.Status(ctx)
+	req, err := client.NewRequest(ctx, "GET", "anything", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Do(req, nil)
+
+	want := []string{"SubUsers.Get", "Locations.Countries", "TwoFactor.Status", ""}
+	if !reflect.DeepEqual(ops, want) {
+		t.Errorf("operations = %q, want %q", ops, want)
+	}
+}
@@ -28,3 +28,12 @@ func WithTimeout(d time.Duration) Option {
 		c.timeout = d
 	}
 }
+
+// WithRetryPolicy enables automatic retries of failed requests. Zero fields
+// take their value from DefaultRetryPolicy.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *Client) {
+		p = p.withDefaults()
+		c.retryPolicy = &p
+	}
+}
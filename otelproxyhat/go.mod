@@ -0,0 +1,23 @@
+module github.com/ProxyHatCom/go-sdk/otelproxyhat
+
+go 1.21
+
+require (
+	github.com/ProxyHatCom/go-sdk v0.2.0
+	go.opentelemetry.io/otel v1.28.0
+	go.opentelemetry.io/otel/sdk v1.28.0
+	go.opentelemetry.io/otel/trace v1.28.0
+)
+
+require (
+	github.com/go-logr/logr v1.4.2 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	go.opentelemetry.io/otel/metric v1.28.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+)
+
+// The SDK's tracing API first ships in v0.2.0. The replace directive only
+// applies inside this repository, so tag the root module before tagging
+// otelproxyhat/v* or downstream builds cannot resolve it.
+replace github.com/ProxyHatCom/go-sdk => ../
@@ -0,0 +1,110 @@
+// Package otelproxyhat adapts OpenTelemetry tracing to the ProxyHat Go SDK.
+// It lives in its own module so that the SDK itself stays free of external
+// dependencies, and requires github.com/ProxyHatCom/go-sdk v0.2.0 or later.
+// When releasing, tag the root module before otelproxyhat/v*.
+//
+// Usage:
+//
+//	client := proxyhat.NewClient("your-api-key",
+//		proxyhat.WithTracer(otelproxyhat.NewTracer(otel.GetTracerProvider())),
+//	)
+package otelproxyhat
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	proxyhat "github.com/ProxyHatCom/go-sdk"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName identifies the SDK as the source of spans.
+const InstrumentationName = "github.com/ProxyHatCom/go-sdk"
+
+// NewTracer returns a proxyhat.Tracer that records client spans with tp.
+// A nil tp means the global TracerProvider.
+func NewTracer(tp trace.TracerProvider) proxyhat.Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return &tracer{tracer: tp.Tracer(InstrumentationName)}
+}
+
+type tracer struct {
+	tracer trace.Tracer
+}
+
+func (t *tracer) Start(ctx context.Context, name string) (context.Context, proxyhat.Span) {
+	ctx, s := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+	return ctx, span{s}
+}
+
+type span struct {
+	span trace.Span
+}
+
+func (s span) SetAttributes(attrs ...slog.Attr) {
+	s.span.SetAttributes(keyValues(attrs)...)
+}
+
+func (s span) AddEvent(name string, attrs ...slog.Attr) {
+	s.span.AddEvent(name, trace.WithAttributes(keyValues(attrs)...))
+}
+
+func (s span) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+func (s span) End() {
+	s.span.End()
+}
+
+func (s span) TraceParent() string {
+	sc := s.span.SpanContext()
+	if !sc.IsValid() {
+		return ""
+	}
+	return "00-" + sc.TraceID().String() + "-" + sc.SpanID().String() + "-" + sc.TraceFlags().String()
+}
+
+// keyValues converts slog attributes to OpenTelemetry ones. Durations are
+// recorded in seconds and groups are flattened with dotted keys.
+func keyValues(attrs []slog.Attr) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		kvs = appendKeyValue(kvs, "", a)
+	}
+	return kvs
+}
+
+func appendKeyValue(kvs []attribute.KeyValue, prefix string, a slog.Attr) []attribute.KeyValue {
+	key := prefix + a.Key
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return append(kvs, attribute.String(key, v.String()))
+	case slog.KindInt64:
+		return append(kvs, attribute.Int64(key, v.Int64()))
+	case slog.KindUint64:
+		return append(kvs, attribute.Int64(key, int64(v.Uint64())))
+	case slog.KindFloat64:
+		return append(kvs, attribute.Float64(key, v.Float64()))
+	case slog.KindBool:
+		return append(kvs, attribute.Bool(key, v.Bool()))
+	case slog.KindDuration:
+		return append(kvs, attribute.Float64(key, v.Duration().Seconds()))
+	case slog.KindTime:
+		return append(kvs, attribute.String(key, v.Time().Format(time.RFC3339Nano)))
+	case slog.KindGroup:
+		for _, ga := range v.Group() {
+			kvs = appendKeyValue(kvs, key+".", ga)
+		}
+		return kvs
+	}
+	return append(kvs, attribute.String(key, v.String()))
+}
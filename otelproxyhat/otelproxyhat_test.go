@@ -0,0 +1,67 @@
+package otelproxyhat
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	proxyhat "github.com/ProxyHatCom/go-sdk"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestNewTracer(t *testing.T) {
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("Traceparent")
+		if r.URL.Path == "/sub-users/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"message": "not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"payload": []any{}})
+	}))
+	defer server.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	client := proxyhat.NewClient("key", proxyhat.WithBaseURL(server.URL), proxyhat.WithTracer(NewTracer(tp)))
+
+	if _, err := client.SubUsers.List(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("spans = %d, want 1", len(spans))
+	}
+	s := spans[0]
+	sc := s.SpanContext()
+	if want := "00-" + sc.TraceID().String() + "-" + sc.SpanID().String() + "-01"; traceparent != want {
+		t.Errorf("traceparent = %q, want %q", traceparent, want)
+	}
+	if s.Name() != "SubUsers.List" || s.InstrumentationScope().Name != InstrumentationName {
+		t.Errorf("span = %s from %s", s.Name(), s.InstrumentationScope().Name)
+	}
+	attrs := attribute.NewSet(s.Attributes()...)
+	if v, _ := attrs.Value("http.response.status_code"); v.AsInt64() != 200 {
+		t.Errorf("attributes = %v", s.Attributes())
+	}
+	var names []string
+	for _, e := range s.Events() {
+		names = append(names, e.Name)
+	}
+	if !strings.Contains(strings.Join(names, ","), "connect_done") {
+		t.Errorf("events = %v", names)
+	}
+
+	client.SubUsers.Get(context.Background(), "missing")
+	failed := recorder.Ended()[1]
+	if failed.Status().Code != codes.Error || len(failed.Events()) == 0 {
+		t.Errorf("status = %v", failed.Status())
+	}
+}
@@ -0,0 +1,78 @@
+package proxyhat
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidArgument is matched with errors.Is by errors for arguments
+// rejected before any request is sent, such as an empty resource ID.
+var ErrInvalidArgument = errors.New("proxyhat: invalid argument")
+
+// ArgumentError reports an invalid argument to an SDK method.
+type ArgumentError struct {
+	// Name is the argument's name, e.g. "id".
+	Name   string
+	Value  string
+	Reason string
+}
+
+func (e *ArgumentError) Error() string {
+	return fmt.Sprintf("proxyhat: invalid argument %s %q: %s", e.Name, e.Value, e.Reason)
+}
+
+// Is makes errors.Is(err, ErrInvalidArgument) report true.
+func (e *ArgumentError) Is(target error) bool {
+	return target == ErrInvalidArgument
+}
+
+// WithUUIDValidation makes SubUsersService methods reject sub-user IDs that
+// are not UUIDs with an ArgumentError instead of sending the request.
+func WithUUIDValidation() Option {
+	return func(c *Client) {
+		c.checkUUIDs = true
+	}
+}
+
+var uuidShape = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+func checkUUID(name, value string) error {
+	if !uuidShape.MatchString(value) {
+		return &ArgumentError{Name: name, Value: value, Reason: "must be a UUID"}
+	}
+	return nil
+}
+
+// buildPath fills the {name} placeholders of template with args in order,
+// escaping each as a single path segment. Empty arguments and the dot
+// segments "." and ".." are rejected, as they would address a different
+// endpoint.
+func buildPath(template string, args ...string) (string, error) {
+	var b strings.Builder
+	rest := template
+	for _, arg := range args {
+		start := strings.IndexByte(rest, '{')
+		end := strings.IndexByte(rest, '}')
+		if start < 0 || end < start {
+			panic("proxyhat: path template " + template + " has too few placeholders")
+		}
+		name := rest[start+1 : end]
+		switch {
+		case strings.TrimSpace(arg) == "":
+			return "", &ArgumentError{Name: name, Value: arg, Reason: "must not be empty"}
+		case arg == "." || arg == "..":
+			return "", &ArgumentError{Name: name, Value: arg, Reason: "is not a valid path segment"}
+		}
+		b.WriteString(rest[:start])
+		b.WriteString(url.PathEscape(arg))
+		rest = rest[end+1:]
+	}
+	if strings.IndexByte(rest, '{') >= 0 {
+		panic("proxyhat: path template " + template + " has too many placeholders")
+	}
+	b.WriteString(rest)
+	return b.String(), nil
+}
@@ -0,0 +1,97 @@
+package proxyhat
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestBuildPath(t *testing.T) {
+	tests := []struct {
+		template string
+		args     []string
+		want     string
+	}{
+		{"sub-users/{id}", []string{"su-1"}, "sub-users/su-1"},
+		{"sub-users/{id}", []string{"a/b"}, "sub-users/a%2Fb"},
+		{"payments/{id}/check", []string{"x y?z"}, "payments/x%20y%3Fz/check"},
+		{"auth/{provider}/redirect", []string{"..."}, "auth/.../redirect"},
+	}
+	for _, tt := range tests {
+		got, err := buildPath(tt.template, tt.args...)
+		if err != nil || got != tt.want {
+			t.Errorf("buildPath(%q, %q) = %q, %v; want %q", tt.template, tt.args, got, err, tt.want)
+		}
+	}
+
+	for _, arg := range []string{"", "  ", ".", ".."} {
+		_, err := buildPath("sub-users/{id}", arg)
+		var ae *ArgumentError
+		if !errors.As(err, &ae) || ae.Name != "id" || !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("buildPath(%q) error = %v, want ArgumentError for id", arg, err)
+		}
+	}
+}
+
+func TestServices_EscapeIDs(t *testing.T) {
+	client, mux, cleanup := setupTest()
+	defer cleanup()
+
+	var got string
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		got = r.RequestURI
+		writePayload(w, map[string]string{})
+	})
+
+	if _, err := client.ProxyPresets.Get(context.Background(), "a/b c"); err != nil {
+		t.Fatal(err)
+	}
+	if got != "/proxy-presets/a%2Fb%20c" {
+		t.Errorf("path = %q", got)
+	}
+}
+
+func TestServices_RejectEmptyIDs(t *testing.T) {
+	client, mux, cleanup := setupTest()
+	defer cleanup()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+	})
+
+	ctx := context.Background()
+	calls := map[string]error{}
+	_, calls["SubUsers.Get"] = client.SubUsers.Get(ctx, "")
+	calls["SubUsers.Delete"] = client.SubUsers.Delete(ctx, "")
+	_, calls["SubUserGroups.Update"] = client.SubUserGroups.Update(ctx, " ", UpdateSubUserGroupParams{})
+	_, calls["Payments.Invoice"] = client.Payments.Invoice(ctx, "", "")
+	_, calls["Plans.GetRegular"] = client.Plans.GetRegular(ctx, "")
+	calls["Profile.DeleteAPIKey"] = client.Profile.DeleteAPIKey(ctx, "")
+	calls["Auth.DisconnectSocial"] = client.Auth.DisconnectSocial(ctx, "..")
+	for name, err := range calls {
+		if !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("%s error = %v, want ErrInvalidArgument", name, err)
+		}
+	}
+}
+
+func TestWithUUIDValidation(t *testing.T) {
+	client, mux, cleanup := setupTest(WithUUIDValidation())
+	defer cleanup()
+
+	const id = "0b6c1a52-3f1e-4c8e-9a0d-5f2d1e7b9c34"
+	mux.HandleFunc("/sub-users/"+id, func(w http.ResponseWriter, r *http.Request) {
+		writePayload(w, SubUser{UUID: id})
+	})
+
+	ctx := context.Background()
+	if _, err := client.SubUsers.Get(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	err := client.SubUsers.Delete(ctx, "su-1")
+	var ae *ArgumentError
+	if !errors.As(err, &ae) || ae.Reason != "must be a UUID" {
+		t.Errorf("Delete error = %v, want UUID ArgumentError", err)
+	}
+}
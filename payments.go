@@ -22,7 +22,7 @@ type CreatePaymentParams struct {
 // List returns all payments.
 func (s *PaymentsService) List(ctx context.Context) ([]Payment, error) {
 	var result []Payment
-	err := s.client.doRequest(ctx, "GET", "payments", nil, &result)
+	err := s.client.doRequest(ctx, "Payments.List", "GET", "payments", nil, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -35,7 +35,7 @@ func (s *PaymentsService) Create(ctx context.Context, params CreatePaymentParams
 		params.Gate = "crypto"
 	}
 	var result PaymentCreateResponse
-	err := s.client.doRequest(ctx, "POST", "payments", params, &result)
+	err := s.client.doRequest(ctx, "Payments.Create", "POST", "payments", params, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -45,7 +45,11 @@ func (s *PaymentsService) Create(ctx context.Context, params CreatePaymentParams
 // Get returns a payment by ID.
 func (s *PaymentsService) Get(ctx context.Context, id string) (*PaymentDetails, error) {
 	var result PaymentDetails
-	err := s.client.doRequest(ctx, "GET", "payments/"+id, nil, &result)
+	path, err := buildPath("payments/{id}", id)
+	if err != nil {
+		return nil, err
+	}
+	err = s.client.doRequest(ctx, "Payments.Get", "GET", path, nil, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -55,7 +59,11 @@ func (s *PaymentsService) Get(ctx context.Context, id string) (*PaymentDetails,
 // Check checks the status of a payment.
 func (s *PaymentsService) Check(ctx context.Context, id string) (*PaymentDetails, error) {
 	var result PaymentDetails
-	err := s.client.doRequest(ctx, "GET", "payments/"+id+"/check", nil, &result)
+	path, err := buildPath("payments/{id}/check", id)
+	if err != nil {
+		return nil, err
+	}
+	err = s.client.doRequest(ctx, "Payments.Check", "GET", path, nil, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -69,13 +77,17 @@ func (s *PaymentsService) Invoice(ctx context.Context, id string, format string)
 	}
 	params := url.Values{}
 	params.Set("format", format)
-	return s.client.doRequestRaw(ctx, "GET", "payments/"+id+"/invoice", params)
+	path, err := buildPath("payments/{id}/invoice", id)
+	if err != nil {
+		return nil, err
+	}
+	return s.client.doRequestRaw(ctx, "Payments.Invoice", "GET", path, params)
 }
 
 // Cryptocurrencies returns the list of supported cryptocurrencies.
 func (s *PaymentsService) Cryptocurrencies(ctx context.Context) ([]Cryptocurrency, error) {
 	var result []Cryptocurrency
-	err := s.client.doRequest(ctx, "GET", "payments/cryptocurrencies", nil, &result)
+	err := s.client.doRequest(ctx, "Payments.Cryptocurrencies", "GET", "payments/cryptocurrencies", nil, &result)
 	if err != nil {
 		return nil, err
 	}
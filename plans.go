@@ -10,7 +10,7 @@ type PlansService struct {
 // ListRegular returns all regular (one-time) plans.
 func (s *PlansService) ListRegular(ctx context.Context) ([]RegularPlan, error) {
 	var result []RegularPlan
-	err := s.client.doRequest(ctx, "GET", "regular-options", nil, &result)
+	err := s.client.doRequest(ctx, "Plans.ListRegular", "GET", "regular-options", nil, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -20,7 +20,7 @@ func (s *PlansService) ListRegular(ctx context.Context) ([]RegularPlan, error) {
 // ListSubscriptions returns all subscription plans.
 func (s *PlansService) ListSubscriptions(ctx context.Context) ([]SubscriptionPlan, error) {
 	var result []SubscriptionPlan
-	err := s.client.doRequest(ctx, "GET", "subscription-plans", nil, &result)
+	err := s.client.doRequest(ctx, "Plans.ListSubscriptions", "GET", "subscription-plans", nil, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -30,7 +30,11 @@ func (s *PlansService) ListSubscriptions(ctx context.Context) ([]SubscriptionPla
 // GetRegular returns a regular plan by name.
 func (s *PlansService) GetRegular(ctx context.Context, name string) (*RegularPlan, error) {
 	var result RegularPlan
-	err := s.client.doRequest(ctx, "GET", "plans/regular/"+name, nil, &result)
+	path, err := buildPath("plans/regular/{name}", name)
+	if err != nil {
+		return nil, err
+	}
+	err = s.client.doRequest(ctx, "Plans.GetRegular", "GET", path, nil, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -40,7 +44,11 @@ func (s *PlansService) GetRegular(ctx context.Context, name string) (*RegularPla
 // GetSubscription returns a subscription plan by name.
 func (s *PlansService) GetSubscription(ctx context.Context, name string) (*SubscriptionPlan, error) {
 	var result SubscriptionPlan
-	err := s.client.doRequest(ctx, "GET", "plans/subscription/"+name, nil, &result)
+	path, err := buildPath("plans/subscription/{name}", name)
+	if err != nil {
+		return nil, err
+	}
+	err = s.client.doRequest(ctx, "Plans.GetSubscription", "GET", path, nil, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -50,7 +58,7 @@ func (s *PlansService) GetSubscription(ctx context.Context, name string) (*Subsc
 // PricingRegular returns regular plan pricing.
 func (s *PlansService) PricingRegular(ctx context.Context) ([]any, error) {
 	var result []any
-	err := s.client.doRequest(ctx, "GET", "pricing/regular", nil, &result)
+	err := s.client.doRequest(ctx, "Plans.PricingRegular", "GET", "pricing/regular", nil, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -60,7 +68,7 @@ func (s *PlansService) PricingRegular(ctx context.Context) ([]any, error) {
 // PricingSubscriptions returns subscription plan pricing.
 func (s *PlansService) PricingSubscriptions(ctx context.Context) ([]any, error) {
 	var result []any
-	err := s.client.doRequest(ctx, "GET", "pricing/subscriptions", nil, &result)
+	err := s.client.doRequest(ctx, "Plans.PricingSubscriptions", "GET", "pricing/subscriptions", nil, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -10,7 +10,7 @@ type ProfileService struct {
 // GetPreferences returns user preferences.
 func (s *ProfileService) GetPreferences(ctx context.Context) (*Preferences, error) {
 	var result Preferences
-	err := s.client.doRequest(ctx, "GET", "profile/preferences", nil, &result)
+	err := s.client.doRequest(ctx, "Profile.GetPreferences", "GET", "profile/preferences", nil, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -20,7 +20,7 @@ func (s *ProfileService) GetPreferences(ctx context.Context) (*Preferences, erro
 // UpdatePreferences updates user preferences.
 func (s *ProfileService) UpdatePreferences(ctx context.Context, preferences map[string]any) (*Preferences, error) {
 	var result Preferences
-	err := s.client.doRequest(ctx, "PUT", "profile/preferences", preferences, &result)
+	err := s.client.doRequest(ctx, "Profile.UpdatePreferences", "PUT", "profile/preferences", preferences, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -30,7 +30,7 @@ func (s *ProfileService) UpdatePreferences(ctx context.Context, preferences map[
 // ListAPIKeys returns all API keys.
 func (s *ProfileService) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
 	var result []APIKey
-	err := s.client.doRequest(ctx, "GET", "profile/api-keys", nil, &result)
+	err := s.client.doRequest(ctx, "Profile.ListAPIKeys", "GET", "profile/api-keys", nil, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -44,7 +44,7 @@ func (s *ProfileService) CreateAPIKey(ctx context.Context, name *string) (*APIKe
 		body = map[string]string{"name": *name}
 	}
 	var result APIKey
-	err := s.client.doRequest(ctx, "POST", "profile/api-keys", body, &result)
+	err := s.client.doRequest(ctx, "Profile.CreateAPIKey", "POST", "profile/api-keys", body, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -53,13 +53,21 @@ func (s *ProfileService) CreateAPIKey(ctx context.Context, name *string) (*APIKe
 
 // DeleteAPIKey deletes an API key by ID.
 func (s *ProfileService) DeleteAPIKey(ctx context.Context, id string) error {
-	return s.client.doRequest(ctx, "DELETE", "profile/api-keys/"+id, nil, nil)
+	path, err := buildPath("profile/api-keys/{id}", id)
+	if err != nil {
+		return err
+	}
+	return s.client.doRequest(ctx, "Profile.DeleteAPIKey", "DELETE", path, nil, nil)
 }
 
 // RegenerateAPIKey regenerates an API key.
 func (s *ProfileService) RegenerateAPIKey(ctx context.Context, id string) (*APIKey, error) {
 	var result APIKey
-	err := s.client.doRequest(ctx, "POST", "profile/api-keys/"+id+"/regenerate", nil, &result)
+	path, err := buildPath("profile/api-keys/{id}/regenerate", id)
+	if err != nil {
+		return nil, err
+	}
+	err = s.client.doRequest(ctx, "Profile.RegenerateAPIKey", "POST", path, nil, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -20,7 +20,7 @@ type UpdateProxyPresetParams struct {
 // List returns all proxy presets.
 func (s *ProxyPresetsService) List(ctx context.Context) ([]ProxyPreset, error) {
 	var result []ProxyPreset
-	err := s.client.doRequest(ctx, "GET", "proxy-presets", nil, &result)
+	err := s.client.doRequest(ctx, "ProxyPresets.List", "GET", "proxy-presets", nil, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -30,7 +30,7 @@ func (s *ProxyPresetsService) List(ctx context.Context) ([]ProxyPreset, error) {
 // Create creates a new proxy preset.
 func (s *ProxyPresetsService) Create(ctx context.Context, params CreateProxyPresetParams) (*ProxyPreset, error) {
 	var result ProxyPreset
-	err := s.client.doRequest(ctx, "POST", "proxy-presets", params, &result)
+	err := s.client.doRequest(ctx, "ProxyPresets.Create", "POST", "proxy-presets", params, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -40,7 +40,11 @@ func (s *ProxyPresetsService) Create(ctx context.Context, params CreateProxyPres
 // Get returns a proxy preset by ID.
 func (s *ProxyPresetsService) Get(ctx context.Context, id string) (*ProxyPreset, error) {
 	var result ProxyPreset
-	err := s.client.doRequest(ctx, "GET", "proxy-presets/"+id, nil, &result)
+	path, err := buildPath("proxy-presets/{id}", id)
+	if err != nil {
+		return nil, err
+	}
+	err = s.client.doRequest(ctx, "ProxyPresets.Get", "GET", path, nil, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -50,7 +54,11 @@ func (s *ProxyPresetsService) Get(ctx context.Context, id string) (*ProxyPreset,
 // Update modifies a proxy preset.
 func (s *ProxyPresetsService) Update(ctx context.Context, id string, params UpdateProxyPresetParams) (*ProxyPreset, error) {
 	var result ProxyPreset
-	err := s.client.doRequest(ctx, "PUT", "proxy-presets/"+id, params, &result)
+	path, err := buildPath("proxy-presets/{id}", id)
+	if err != nil {
+		return nil, err
+	}
+	err = s.client.doRequest(ctx, "ProxyPresets.Update", "PUT", path, params, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -59,5 +67,9 @@ func (s *ProxyPresetsService) Update(ctx context.Context, id string, params Upda
 
 // Delete removes a proxy preset.
 func (s *ProxyPresetsService) Delete(ctx context.Context, id string) error {
-	return s.client.doRequest(ctx, "DELETE", "proxy-presets/"+id, nil, nil)
+	path, err := buildPath("proxy-presets/{id}", id)
+	if err != nil {
+		return err
+	}
+	return s.client.doRequest(ctx, "ProxyPresets.Delete", "DELETE", path, nil, nil)
 }
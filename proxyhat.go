@@ -12,9 +12,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -31,6 +33,22 @@ type Client struct {
 	httpClient *http.Client
 	timeout    time.Duration
 
+	retryPolicy *RetryPolicy
+	limiter     *rateLimiter
+	breaker     *circuitBreaker
+	cache       *responseCache
+	coalesce    bool
+	checkUUIDs  bool
+	sleep       func(context.Context, time.Duration) error
+	logger      *slog.Logger
+	tracer      Tracer
+	metrics     MetricsCollector
+	middleware  []Middleware
+	handler     Handler
+
+	// rateLimitState is the last state reported by the API.
+	rateLimitState atomic.Pointer[RateLimitState]
+
 	Auth         *AuthService
 	SubUsers     *SubUsersService
 	SubUserGroups *SubUserGroupsService
@@ -52,6 +70,7 @@ func NewClient(apiKey string, opts ...Option) *Client {
 		baseURL:    DefaultBaseURL,
 		httpClient: &http.Client{},
 		timeout:    DefaultTimeout,
+		sleep:      sleepContext,
 	}
 
 	for _, opt := range opts {
@@ -59,6 +78,7 @@ func NewClient(apiKey string, opts ...Option) *Client {
 	}
 
 	c.httpClient.Timeout = c.timeout
+	c.handler = chain(append(c.builtinMiddleware(), c.middleware...), c.send)
 
 	c.Auth = &AuthService{client: c}
 	c.SubUsers = &SubUsersService{client: c}
@@ -76,88 +96,184 @@ func NewClient(apiKey string, opts ...Option) *Client {
 	return c
 }
 
-func (c *Client) doRequest(ctx context.Context, method, path string, body any, result any) error {
-	var bodyReader io.Reader
-	if body != nil {
-		jsonBody, err := json.Marshal(body)
-		if err != nil {
-			return fmt.Errorf("failed to marshal request body: %w", err)
-		}
-		bodyReader = bytes.NewReader(jsonBody)
-	}
+// doRequest calls the API on behalf of the service method op, e.g.
+// "SubUsers.Create".
+func (c *Client) doRequest(ctx context.Context, op, method, path string, body any, result any) error {
+	_, err := c.do(&Request{Method: method, Path: path, Body: body, Result: result, Operation: op, ctx: ctx})
+	return err
+}
 
-	reqURL := strings.TrimRight(c.baseURL, "/") + "/" + strings.TrimLeft(path, "/")
-	req, err := http.NewRequestWithContext(ctx, method, reqURL, bodyReader)
+func (c *Client) doRequestWithParams(ctx context.Context, op, method, path string, params url.Values, result any) error {
+	_, err := c.do(&Request{Method: method, Path: path, Params: params, Result: result, Operation: op, ctx: ctx})
+	return err
+}
+
+func (c *Client) doRequestRaw(ctx context.Context, op, method, path string, params url.Values) (*http.Response, error) {
+	resp, err := c.do(&Request{Method: method, Path: path, Params: params, Operation: op, raw: true, ctx: ctx})
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
+	return resp.Response, nil
+}
 
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("User-Agent", userAgent)
-	if body != nil {
-		req.Header.Set("Content-Type", "application/json")
-	}
+// do runs req through the middleware chain.
+func (c *Client) do(req *Request) (*Response, error) {
+	resp, err := c.handler(req)
+	captureResponse(req.Context(), resp)
+	return resp, err
+}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
+// send is the innermost Handler. It performs req, retrying according to the
+// client's retry policy, and decodes the response into req.Result. API
+// errors are returned as *Error, *RateLimitError or *ValidationError
+// together with the response.
+func (c *Client) send(req *Request) (*Response, error) {
+	var body []byte
+	if req.Body != nil {
+		jsonBody, err := json.Marshal(req.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request body: %w", err)
+		}
+		body = jsonBody
 	}
-	defer resp.Body.Close()
 
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response body: %w", err)
+	resp, attempts, err := c.sendWithRetry(req, body)
+	if resp == nil {
+		return nil, err
 	}
-
-	if err := checkResponse(resp, respBody); err != nil {
-		return err
+	r := &Response{Response: resp, Attempts: attempts, size: resp.ContentLength, sent: int64(len(body)), received: time.Now()}
+	if err != nil || req.raw {
+		return r, err
 	}
+	return r, decodeResponse(r, req.Result)
+}
 
-	if result == nil {
-		return nil
+// sendWithRetry sends the request until it succeeds or the retry policy
+// gives up, and reports how many attempts were made. On success the
+// response body is left unread.
+func (c *Client) sendWithRetry(req *Request, body []byte) (*http.Response, int, error) {
+	ctx := req.Context()
+	policy := c.retryPolicy
+	if policy == nil || !retryAllowed(ctx, req.Method) {
+		httpReq, err := c.newHTTPRequest(req, body)
+		if err != nil {
+			return nil, 0, err
+		}
+		resp, err := c.roundTrip(httpReq, req.Path)
+		return resp, 1, err
 	}
 
-	// Try envelope: look for "payload" or "data" key
-	var envelope map[string]json.RawMessage
-	if err := json.Unmarshal(respBody, &envelope); err == nil {
-		if payload, ok := envelope["payload"]; ok {
-			return json.Unmarshal(payload, result)
+	var history []RetryAttempt
+	for attempt := 1; ; attempt++ {
+		httpReq, err := c.newHTTPRequest(req, body)
+		if err != nil {
+			return nil, attempt - 1, retryHistoryError(history, err)
 		}
-		if data, ok := envelope["data"]; ok {
-			return json.Unmarshal(data, result)
+		resp, err := c.roundTrip(httpReq, req.Path)
+		if err == nil {
+			return resp, attempt, nil
 		}
-	}
 
-	return json.Unmarshal(respBody, result)
+		record := RetryAttempt{Err: err}
+		if resp != nil {
+			record.StatusCode = resp.StatusCode
+		}
+		if attempt >= policy.MaxAttempts || !policy.retryable(ctx, resp, err) {
+			return resp, attempt, retryHistoryError(append(history, record), err)
+		}
+		wait := policy.backoff(attempt, resp)
+		if wait > policy.MaxDelay {
+			// The server asked for a longer pause than the policy allows.
+			return resp, attempt, retryHistoryError(append(history, record), err)
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			// The context would expire before the next attempt.
+			return resp, attempt, retryHistoryError(append(history, record), err)
+		}
+		record.Wait = wait
+		history = append(history, record)
+		if err := c.sleep(ctx, record.Wait); err != nil {
+			return resp, attempt, &RetryError{Attempts: history, Err: err}
+		}
+	}
 }
 
-func (c *Client) doRequestWithParams(ctx context.Context, method, path string, params url.Values, result any) error {
-	reqURL := strings.TrimRight(c.baseURL, "/") + "/" + strings.TrimLeft(path, "/")
-	if len(params) > 0 {
-		reqURL += "?" + params.Encode()
+func (c *Client) newHTTPRequest(r *Request, body []byte) (*http.Request, error) {
+	reqURL := strings.TrimRight(c.baseURL, "/") + "/" + strings.TrimLeft(r.Path, "/")
+	if len(r.Params) > 0 {
+		reqURL += "?" + r.Params.Encode()
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, reqURL, nil)
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, reqURL, bodyReader)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Authorization", "Bearer "+c.apiKey)
-	req.Header.Set("Accept", "application/json")
+	if !r.raw {
+		req.Header.Set("Accept", "application/json")
+	}
 	req.Header.Set("User-Agent", userAgent)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range r.Header {
+		req.Header[k] = v
+	}
+	return req, nil
+}
+
+// roundTrip sends req for the API path once, after waiting for the rate
+// limiter. When the API returns an error status, the response is returned
+// alongside the error with its body already closed.
+func (c *Client) roundTrip(req *http.Request, path string) (*http.Response, error) {
+	if c.limiter != nil {
+		if err := c.limiter.wait(req.Context(), path, c.sleep); err != nil {
+			return nil, err
+		}
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
+		return nil, &TransportError{Method: req.Method, Path: req.URL.Path, Err: err}
 	}
+	if state, ok := parseRateLimitState(resp.Header, time.Now()); ok {
+		c.rateLimitState.Store(&state)
+	}
+	if c.limiter != nil {
+		c.limiter.observe(path, resp)
+	}
+
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return resp, readError(resp, err)
+		}
+		return resp, checkResponse(resp, body)
+	}
+
+	return resp, nil
+}
+
+func decodeResponse(r *Response, result any) error {
+	resp := r.Response
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("failed to read response body: %w", err)
+		return readError(resp, err)
 	}
+	r.size = int64(len(respBody))
 
+	// A conditional request was answered from the caller's cached copy.
+	if resp.StatusCode == http.StatusNotModified {
+		return nil
+	}
 	if err := checkResponse(resp, respBody); err != nil {
 		return err
 	}
@@ -166,43 +282,40 @@ func (c *Client) doRequestWithParams(ctx context.Context, method, path string, p
 		return nil
 	}
 
-	var envelope map[string]json.RawMessage
-	if err := json.Unmarshal(respBody, &envelope); err == nil {
-		if payload, ok := envelope["payload"]; ok {
-			return json.Unmarshal(payload, result)
-		}
-		if data, ok := envelope["data"]; ok {
-			return json.Unmarshal(data, result)
+	r.payload = payloadOf(respBody)
+	if err := json.Unmarshal(r.payload, result); err != nil {
+		return &DecodeError{
+			StatusCode:  resp.StatusCode,
+			ContentType: resp.Header.Get("Content-Type"),
+			Body:        respBody,
+			Err:         err,
 		}
 	}
-
-	return json.Unmarshal(respBody, result)
+	return nil
 }
 
-func (c *Client) doRequestRaw(ctx context.Context, method, path string, params url.Values) (*http.Response, error) {
-	reqURL := strings.TrimRight(c.baseURL, "/") + "/" + strings.TrimLeft(path, "/")
-	if len(params) > 0 {
-		reqURL += "?" + params.Encode()
-	}
-
-	req, err := http.NewRequestWithContext(ctx, method, reqURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
-	req.Header.Set("User-Agent", userAgent)
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+func readError(resp *http.Response, err error) error {
+	e := &TransportError{Err: fmt.Errorf("failed to read response body: %w", err)}
+	if resp.Request != nil {
+		e.Method = resp.Request.Method
+		e.Path = resp.Request.URL.Path
 	}
+	return e
+}
 
-	if resp.StatusCode >= 400 {
-		defer resp.Body.Close()
-		body, _ := io.ReadAll(resp.Body)
-		return nil, checkResponse(resp, body)
+// payloadOf returns the "payload" or "data" member of an enveloped
+// response body, or the whole body if it has no envelope.
+func payloadOf(respBody []byte) json.RawMessage {
+	// Try envelope: look for "payload" or "data" key
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(respBody, &envelope); err == nil {
+		if payload, ok := envelope["payload"]; ok {
+			return payload
+		}
+		if data, ok := envelope["data"]; ok {
+			return data
+		}
 	}
 
-	return resp, nil
+	return respBody
 }
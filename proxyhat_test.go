@@ -10,10 +10,10 @@ import (
 	"time"
 )
 
-func setupTest() (*Client, *http.ServeMux, func()) {
+func setupTest(opts ...Option) (*Client, *http.ServeMux, func()) {
 	mux := http.NewServeMux()
 	server := httptest.NewServer(mux)
-	client := NewClient("test-api-key", WithBaseURL(server.URL))
+	client := NewClient("test-api-key", append([]Option{WithBaseURL(server.URL)}, opts...)...)
 	return client, mux, server.Close
 }
 
@@ -120,7 +120,7 @@ func TestDoRequest_SetsHeaders(t *testing.T) {
 	})
 
 	var result map[string]string
-	err := client.doRequest(context.Background(), "GET", "test", nil, &result)
+	err := client.doRequest(context.Background(), "", "GET", "test", nil, &result)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -135,7 +135,7 @@ func TestDoRequest_EnvelopePayload(t *testing.T) {
 	})
 
 	var result map[string]string
-	err := client.doRequest(context.Background(), "GET", "test", nil, &result)
+	err := client.doRequest(context.Background(), "", "GET", "test", nil, &result)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -153,7 +153,7 @@ func TestDoRequest_EnvelopeData(t *testing.T) {
 	})
 
 	var result []map[string]string
-	err := client.doRequest(context.Background(), "GET", "test", nil, &result)
+	err := client.doRequest(context.Background(), "", "GET", "test", nil, &result)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -170,7 +170,7 @@ func TestCheckResponse_401(t *testing.T) {
 		writeJSON(w, http.StatusUnauthorized, map[string]string{"message": "Unauthenticated"})
 	})
 
-	err := client.doRequest(context.Background(), "GET", "test", nil, nil)
+	err := client.doRequest(context.Background(), "", "GET", "test", nil, nil)
 	if err == nil {
 		t.Fatal("expected error")
 	}
@@ -187,7 +187,7 @@ func TestCheckResponse_403(t *testing.T) {
 		writeJSON(w, http.StatusForbidden, map[string]string{"message": "Forbidden"})
 	})
 
-	err := client.doRequest(context.Background(), "GET", "test", nil, nil)
+	err := client.doRequest(context.Background(), "", "GET", "test", nil, nil)
 	if !IsPermissionError(err) {
 		t.Errorf("expected permission error, got %v", err)
 	}
@@ -201,7 +201,7 @@ func TestCheckResponse_404(t *testing.T) {
 		writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not found"})
 	})
 
-	err := client.doRequest(context.Background(), "GET", "test", nil, nil)
+	err := client.doRequest(context.Background(), "", "GET", "test", nil, nil)
 	if !IsNotFoundError(err) {
 		t.Errorf("expected not found error, got %v", err)
 	}
@@ -218,7 +218,7 @@ func TestCheckResponse_422(t *testing.T) {
 		})
 	})
 
-	err := client.doRequest(context.Background(), "GET", "test", nil, nil)
+	err := client.doRequest(context.Background(), "", "GET", "test", nil, nil)
 	if !IsValidationError(err) {
 		t.Errorf("expected validation error, got %v", err)
 	}
@@ -233,7 +233,7 @@ func TestCheckResponse_429(t *testing.T) {
 		writeJSON(w, http.StatusTooManyRequests, map[string]string{"message": "Rate limited"})
 	})
 
-	err := client.doRequest(context.Background(), "GET", "test", nil, nil)
+	err := client.doRequest(context.Background(), "", "GET", "test", nil, nil)
 	if !IsRateLimitError(err) {
 		t.Errorf("expected rate limit error, got %v", err)
 	}
@@ -255,7 +255,7 @@ func TestCheckResponse_500(t *testing.T) {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"message": "Internal error"})
 	})
 
-	err := client.doRequest(context.Background(), "GET", "test", nil, nil)
+	err := client.doRequest(context.Background(), "", "GET", "test", nil, nil)
 	if err == nil {
 		t.Fatal("expected error")
 	}
@@ -0,0 +1,228 @@
+package proxyhat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrRateLimitWait is returned, wrapped, when the rate limiter would have to
+// wait past the call's context deadline. The call is not sent.
+var ErrRateLimitWait = errors.New("proxyhat: rate limit wait exceeds context deadline")
+
+// WithRateLimit limits the client to perSecond requests per second with
+// bursts of up to burst requests. Calls block until they may proceed or
+// their context is done.
+//
+// The limiter also follows the API's X-RateLimit-Remaining and
+// X-RateLimit-Reset headers and the Retry-After header of 429 responses,
+// pausing until the reset time once the API reports the budget as spent.
+func WithRateLimit(perSecond float64, burst int) Option {
+	return func(c *Client) {
+		l := c.rateLimiter()
+		l.global = newTokenBucket(perSecond, burst, l.now())
+	}
+}
+
+// WithEndpointRateLimit gives API paths matching pattern their own budget
+// instead of the one set by WithRateLimit. Patterns use path.Match syntax
+// without a leading slash, so "locations/*" matches "locations/countries".
+// The first matching pattern wins.
+func WithEndpointRateLimit(pattern string, perSecond float64, burst int) Option {
+	return func(c *Client) {
+		l := c.rateLimiter()
+		l.endpoints = append(l.endpoints, endpointLimit{
+			pattern: strings.Trim(pattern, "/"),
+			bucket:  newTokenBucket(perSecond, burst, l.now()),
+		})
+	}
+}
+
+func (c *Client) rateLimiter() *rateLimiter {
+	if c.limiter == nil {
+		c.limiter = &rateLimiter{now: time.Now}
+		c.limiter.global = newTokenBucket(0, 1, c.limiter.now())
+	}
+	return c.limiter
+}
+
+type endpointLimit struct {
+	pattern string
+	bucket  *tokenBucket
+}
+
+// rateLimiter holds a global token bucket and per-endpoint overrides.
+type rateLimiter struct {
+	global    *tokenBucket
+	endpoints []endpointLimit
+	now       func() time.Time
+}
+
+func (l *rateLimiter) bucket(apiPath string) *tokenBucket {
+	apiPath = strings.Trim(apiPath, "/")
+	for _, e := range l.endpoints {
+		if ok, _ := path.Match(e.pattern, apiPath); ok {
+			return e.bucket
+		}
+	}
+	return l.global
+}
+
+// wait blocks until a request to apiPath may be sent.
+func (l *rateLimiter) wait(ctx context.Context, apiPath string, sleep func(context.Context, time.Duration) error) error {
+	b := l.bucket(apiPath)
+	d := b.reserve(l.now())
+	if d <= 0 {
+		return nil
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		b.cancel()
+		return fmt.Errorf("%w: need %v", ErrRateLimitWait, d.Round(time.Millisecond))
+	}
+	if err := sleep(ctx, d); err != nil {
+		b.cancel()
+		return err
+	}
+	return nil
+}
+
+// observe adjusts the bucket for apiPath to the budget reported by the API.
+func (l *rateLimiter) observe(apiPath string, resp *http.Response) {
+	now := l.now()
+	b := l.bucket(apiPath)
+	if resp.StatusCode == http.StatusTooManyRequests {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now); ok {
+			b.pause(now.Add(d))
+		}
+	}
+	state, ok := parseRateLimitState(resp.Header, now)
+	if !ok {
+		return
+	}
+	b.capTokens(state.Remaining)
+	if state.Remaining <= 0 && !state.Reset.IsZero() {
+		b.pause(state.Reset)
+	}
+}
+
+// RateLimitState is the API rate limit budget reported by the
+// X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset headers.
+type RateLimitState struct {
+	// Limit is zero if the API did not report it.
+	Limit     int
+	Remaining int
+	// Reset is when the budget refills, or zero if not reported.
+	Reset time.Time
+	// ObservedAt is when the headers were received.
+	ObservedAt time.Time
+}
+
+// RateLimitState returns the rate limit state from the most recent API
+// response that reported one. ObservedAt is zero if none has yet.
+func (c *Client) RateLimitState() RateLimitState {
+	if st := c.rateLimitState.Load(); st != nil {
+		return *st
+	}
+	return RateLimitState{}
+}
+
+func parseRateLimitState(h http.Header, now time.Time) (RateLimitState, bool) {
+	remaining, err := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return RateLimitState{}, false
+	}
+	state := RateLimitState{Remaining: remaining, ObservedAt: now}
+	state.Limit, _ = strconv.Atoi(h.Get("X-RateLimit-Limit"))
+	state.Reset, _ = parseRateLimitReset(h.Get("X-RateLimit-Reset"), now)
+	return state, true
+}
+
+// parseRateLimitReset parses X-RateLimit-Reset, which APIs send either as
+// seconds until the reset or as a Unix timestamp.
+func parseRateLimitReset(v string, now time.Time) (time.Time, bool) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || f < 0 {
+		return time.Time{}, false
+	}
+	// Anything past 2001-09-09 is taken as a timestamp.
+	if f >= 1e9 {
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(frac*1e9)), true
+	}
+	return now.Add(time.Duration(f * float64(time.Second))), true
+}
+
+// tokenBucket is a token bucket that hands out reservations: tokens may go
+// negative, and each caller waits for its own token to be refilled.
+type tokenBucket struct {
+	mu          sync.Mutex
+	rate        float64 // tokens per second; <= 0 means unlimited
+	burst       float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+}
+
+func newTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: now}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed.Seconds()*b.rate)
+		b.last = now
+	}
+}
+
+// reserve takes a token and returns how long to wait before using it.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var wait time.Duration
+	if b.rate > 0 {
+		b.refill(now)
+		b.tokens--
+		if b.tokens < 0 {
+			wait = time.Duration(-b.tokens / b.rate * float64(time.Second))
+		}
+	}
+	if p := b.pausedUntil.Sub(now); p > wait {
+		wait = p
+	}
+	return wait
+}
+
+// cancel returns a token taken by an abandoned reservation.
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.rate > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+1)
+	}
+}
+
+func (b *tokenBucket) capTokens(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.rate > 0 && b.tokens > float64(n) {
+		b.tokens = float64(n)
+	}
+}
+
+func (b *tokenBucket) pause(until time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if until.After(b.pausedUntil) {
+		b.pausedUntil = until
+	}
+}
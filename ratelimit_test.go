@@ -0,0 +1,153 @@
+package proxyhat
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// setupRateLimitTest returns a client whose limiter runs on a fake clock
+// that advances by each wait instead of sleeping.
+func setupRateLimitTest(opts ...Option) (*Client, *http.ServeMux, *[]time.Duration, func()) {
+	client, mux, cleanup := setupTest()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	client.rateLimiter().now = func() time.Time { return now }
+	for _, opt := range opts {
+		opt(client)
+	}
+	var waits []time.Duration
+	client.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		now = now.Add(d)
+		return ctx.Err()
+	}
+	return client, mux, &waits, cleanup
+}
+
+func TestRateLimit_Global(t *testing.T) {
+	client, mux, waits, cleanup := setupRateLimitTest(WithRateLimit(2, 2))
+	defer cleanup()
+	mux.HandleFunc("/sub-users", func(w http.ResponseWriter, r *http.Request) {
+		writePayload(w, []SubUser{})
+	})
+
+	for i := 0; i < 4; i++ {
+		if _, err := client.SubUsers.List(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []time.Duration{500 * time.Millisecond, 500 * time.Millisecond}
+	if len(*waits) != len(want) || (*waits)[0] != want[0] || (*waits)[1] != want[1] {
+		t.Errorf("waits = %v, want %v", *waits, want)
+	}
+}
+
+func TestRateLimit_EndpointOverride(t *testing.T) {
+	client, mux, waits, cleanup := setupRateLimitTest(
+		WithRateLimit(100, 100),
+		WithEndpointRateLimit("locations/*", 1, 1),
+	)
+	defer cleanup()
+	mux.HandleFunc("/sub-users", func(w http.ResponseWriter, r *http.Request) {
+		writePayload(w, []SubUser{})
+	})
+	mux.HandleFunc("/locations/countries", func(w http.ResponseWriter, r *http.Request) {
+		writeData(w, []Country{})
+	})
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.SubUsers.List(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(*waits) != 0 {
+		t.Fatalf("sub-users waits = %v, want none", *waits)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := client.Locations.Countries(context.Background(), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(*waits) != 1 || (*waits)[0] != time.Second {
+		t.Errorf("locations waits = %v, want [1s]", *waits)
+	}
+}
+
+func TestRateLimit_FollowsHeaders(t *testing.T) {
+	client, mux, waits, cleanup := setupRateLimitTest(WithRateLimit(100, 10))
+	defer cleanup()
+	mux.HandleFunc("/sub-users", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "60")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", "30")
+		writePayload(w, []SubUser{})
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.SubUsers.List(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(*waits) != 1 || (*waits)[0] != 30*time.Second {
+		t.Errorf("waits = %v, want [30s]", *waits)
+	}
+}
+
+func TestRateLimit_RetryAfterPauses(t *testing.T) {
+	client, mux, waits, cleanup := setupRateLimitTest(WithRateLimit(100, 10))
+	defer cleanup()
+	mux.HandleFunc("/sub-users", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "5")
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"message": "slow down"})
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.SubUsers.List(context.Background()); !IsRateLimitError(err) {
+			t.Fatalf("err = %v, want rate limit error", err)
+		}
+	}
+	if len(*waits) != 1 || (*waits)[0] != 5*time.Second {
+		t.Errorf("waits = %v, want [5s]", *waits)
+	}
+}
+
+func TestRateLimit_ContextDeadline(t *testing.T) {
+	client, mux, cleanup := setupTest()
+	defer cleanup()
+	WithRateLimit(0.001, 1)(client)
+	mux.HandleFunc("/sub-users", func(w http.ResponseWriter, r *http.Request) {
+		writePayload(w, []SubUser{})
+	})
+
+	if _, err := client.SubUsers.List(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err := client.SubUsers.List(ctx)
+	if !errors.Is(err, ErrRateLimitWait) || errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want ErrRateLimitWait", err)
+	}
+	if !IsRetryable(err) || IsTimeout(err) {
+		t.Errorf("IsRetryable = %v, IsTimeout = %v", IsRetryable(err), IsTimeout(err))
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Error("limiter waited for a deadline it could not meet")
+	}
+}
+
+func TestParseRateLimitReset(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	if got, ok := parseRateLimitReset("30", now); !ok || !got.Equal(now.Add(30*time.Second)) {
+		t.Errorf("delta form = %v, %v", got, ok)
+	}
+	if got, ok := parseRateLimitReset("1700000060", now); !ok || !got.Equal(now.Add(time.Minute)) {
+		t.Errorf("timestamp form = %v, %v", got, ok)
+	}
+	if _, ok := parseRateLimitReset("soon", now); ok {
+		t.Error("expected invalid value to be rejected")
+	}
+}
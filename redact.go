@@ -0,0 +1,141 @@
+package proxyhat
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces secret values in the output of Redact.
+const Redacted = "[REDACTED]"
+
+// redactedKeys are the normalized names of fields whose values are secret,
+// in addition to any name containing one of redactedKeyParts.
+var redactedKeys = map[string]bool{
+	"authorization": true,
+	"twofacode":     true,
+	"recoverycode":  true,
+	"recoverycodes": true,
+	// qr is an otpauth URI carrying the TOTP secret.
+	"qr": true,
+}
+
+// redactedKeyParts mark secret fields such as current_password,
+// plain_text_token and client_secret.
+var redactedKeyParts = []string{"password", "secret", "token"}
+
+func isRedactedKey(k string) bool {
+	k = normalizeKey(k)
+	if redactedKeys[k] {
+		return true
+	}
+	for _, part := range redactedKeyParts {
+		if strings.Contains(k, part) {
+			return true
+		}
+	}
+	return false
+}
+
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+var bearerToken = regexp.MustCompile(`(?i)\b(bearer)(\s+)[^\s"',;]+`)
+
+// Redact returns a copy of v that is safe to log. Secret fields such as
+// passwords, 2FA codes, API tokens and recovery codes are replaced with
+// Redacted, matched by JSON or Go field name regardless of case and
+// underscores; any name containing "password", "secret" or "token" counts.
+// Bearer tokens inside strings are masked too.
+//
+// Structs, maps and slices are converted through their JSON form, so the
+// result is built from maps, slices and scalars. []byte values are treated
+// as JSON documents when they parse as one. http.Header values keep their
+// type, with credential headers masked.
+func Redact(v any) any {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case string:
+		return redactString(v)
+	case []byte:
+		return redactJSON(v)
+	case json.RawMessage:
+		return redactJSON(v)
+	case http.Header:
+		return redactHeader(v)
+	case RecoveryCodes, *RecoveryCodes:
+		// The codes are secret even though the field name is not.
+		return map[string]any{"codes": Redacted}
+	case twoFactorConfirmBody, *twoFactorConfirmBody:
+		return map[string]any{"code": Redacted}
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		// Never fall back to printing a value we could not inspect.
+		return Redacted
+	}
+	return redactJSON(data)
+}
+
+func redactJSON(data []byte) any {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var generic any
+	if err := dec.Decode(&generic); err != nil {
+		return redactString(string(data))
+	}
+	return redactValue(generic)
+}
+
+func redactValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, val := range v {
+			if isRedactedKey(k) {
+				out[k] = Redacted
+				continue
+			}
+			out[k] = redactValue(val)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, val := range v {
+			out[i] = redactValue(val)
+		}
+		return out
+	case string:
+		return redactString(v)
+	}
+	return v
+}
+
+func redactString(s string) string {
+	return bearerToken.ReplaceAllString(s, "${1}${2}"+Redacted)
+}
+
+func redactHeader(h http.Header) http.Header {
+	out := h.Clone()
+	for _, name := range redactedHeaders {
+		if vals := out.Values(name); len(vals) > 0 {
+			masked := make([]string, len(vals))
+			for i, v := range vals {
+				masked[i] = redactString(v)
+				if masked[i] == v {
+					masked[i] = Redacted
+				}
+			}
+			out[http.CanonicalHeaderKey(name)] = masked
+		}
+	}
+	return out
+}
+
+func normalizeKey(k string) string {
+	k = strings.ToLower(k)
+	k = strings.ReplaceAll(k, "_", "")
+	return strings.ReplaceAll(k, "-", "")
+}
@@ -0,0 +1,63 @@
+package proxyhat
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRedact_Structs(t *testing.T) {
+	tests := []struct {
+		name string
+		in   any
+	}{
+		{"login", LoginParams{Email: "a@example.com", Password: "hunter2", TwofaCode: String("123456")}},
+		{"sub-user", CreateSubUserParams{ProxyPassword: "hunter2", Name: String("bot")}},
+		{"api key", &APIKey{ID: "k1", PlainTextToken: String("hunter2")}},
+		{"2fa", TwoFactorEnableResponse{QR: "qr", Secret: "hunter2", RecoveryCodes: []string{"hunter2"}}},
+		{"recovery codes", &RecoveryCodes{Codes: []string{"hunter2"}}},
+		{"2fa confirm", twoFactorConfirmBody{Code: "hunter2"}},
+		{"map", map[string]any{"Recovery_Code": "hunter2", "nested": []any{map[string]string{"PlainTextToken": "hunter2"}}}},
+		{"untagged", struct{ Secret, Name string }{"hunter2", "bot"}},
+		{"json bytes", []byte(`{"password": "hunter2", "note": "Bearer hunter2"}`)},
+		{"string", "Authorization: Bearer hunter2"},
+	}
+	for _, tt := range tests {
+		out, err := json.Marshal(Redact(tt.in))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(out), "hunter2") {
+			t.Errorf("%s: secret leaked: %s", tt.name, out)
+		}
+		if !strings.Contains(string(out), Redacted) {
+			t.Errorf("%s: nothing redacted: %s", tt.name, out)
+		}
+	}
+}
+
+func TestRedact_KeepsOtherFields(t *testing.T) {
+	got := Redact(CreateSubUserParams{ProxyPassword: "hunter2", Name: String("bot"), IsTrafficLimited: true}).(map[string]any)
+	if got["name"] != "bot" || got["is_traffic_limited"] != true || got["proxy_password"] != Redacted {
+		t.Errorf("Redact = %v", got)
+	}
+}
+
+func TestRedact_KeepsLocationCodes(t *testing.T) {
+	got := Redact([]Country{{Code: "US", Name: "United States"}}).([]any)
+	if c := got[0].(map[string]any); c["code"] != "US" {
+		t.Errorf("Redact = %v", got)
+	}
+}
+
+func TestRedact_Header(t *testing.T) {
+	h := http.Header{"Authorization": {"Bearer hunter2"}, "Cookie": {"session=hunter2"}, "Accept": {"application/json"}}
+	got := Redact(h).(http.Header)
+	if got.Get("Authorization") != "Bearer "+Redacted || got.Get("Cookie") != Redacted || got.Get("Accept") != "application/json" {
+		t.Errorf("Redact = %v", got)
+	}
+	if h.Get("Authorization") != "Bearer hunter2" {
+		t.Error("Redact modified its input")
+	}
+}
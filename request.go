@@ -0,0 +1,59 @@
+package proxyhat
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// NewRequest creates a request for an API endpoint the SDK does not wrap
+// yet, to be sent with Do or DoRaw. path is relative to the client's base
+// URL, e.g. "sub-users/123/usage". query and body may be nil; body is
+// encoded as JSON. Set fields of the returned Request, such as Header,
+// before sending it.
+func (c *Client) NewRequest(ctx context.Context, method, path string, query url.Values, body any) (*Request, error) {
+	if ctx == nil {
+		return nil, fmt.Errorf("proxyhat: nil context")
+	}
+	if method == "" || strings.ContainsAny(method, " \t\r\n") {
+		return nil, &ArgumentError{Name: "method", Value: method, Reason: "is not an HTTP method"}
+	}
+	u, err := url.Parse(path)
+	if err != nil || u.IsAbs() || u.Host != "" {
+		return nil, &ArgumentError{Name: "path", Value: path, Reason: "must be relative to the base URL"}
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return nil, &ArgumentError{Name: "path", Value: path, Reason: "must not contain a query; pass it in query"}
+	}
+	return &Request{
+		Method: strings.ToUpper(method),
+		Path:   strings.TrimLeft(path, "/"),
+		Params: query,
+		Body:   body,
+		ctx:    ctx,
+	}, nil
+}
+
+// Do sends req through the client's full pipeline: authentication,
+// middleware, rate limiting, retries and error mapping. The payload is
+// unwrapped from its "payload" or "data" envelope and decoded into out
+// unless out is nil. API errors are returned as *Error, *RateLimitError or
+// *ValidationError together with the response, whose body has been closed.
+func (c *Client) Do(req *Request, out any) (*Response, error) {
+	r := *req
+	r.Result = out
+	r.raw = false
+	return c.do(&r)
+}
+
+// DoRaw sends req like Do but returns the response with its body unread,
+// for streaming downloads such as invoices. The caller must close the
+// body unless an error is returned; API errors are returned like Do's,
+// with the body already closed.
+func (c *Client) DoRaw(req *Request) (*Response, error) {
+	r := *req
+	r.Result = nil
+	r.raw = true
+	return c.do(&r)
+}
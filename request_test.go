@@ -0,0 +1,129 @@
+package proxyhat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestClient_Do(t *testing.T) {
+	client, mux, cleanup := setupTest()
+	defer cleanup()
+
+	mux.HandleFunc("/sub-users/su-1/usage", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("method = %s, want POST", r.Method)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer test-api-key" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if got := r.Header.Get("X-Custom"); got != "yes" {
+			t.Errorf("X-Custom = %q", got)
+		}
+		if got := r.URL.Query().Get("period"); got != "week" {
+			t.Errorf("period = %q", got)
+		}
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["unit"] != "gb" {
+			t.Errorf("body = %v", body)
+		}
+		w.Header().Set("X-Request-Id", "req-7")
+		writePayload(w, map[string]float64{"used": 1.5})
+	})
+
+	req, err := client.NewRequest(context.Background(), "post", "/sub-users/su-1/usage", url.Values{"period": {"week"}}, map[string]string{"unit": "gb"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header = http.Header{"X-Custom": {"yes"}}
+
+	var out struct {
+		Used float64 `json:"used"`
+	}
+	resp, err := client.Do(req, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Used != 1.5 {
+		t.Errorf("used = %v, want 1.5", out.Used)
+	}
+	if resp.StatusCode != http.StatusOK || resp.RequestID() != "req-7" {
+		t.Errorf("resp = %d %q", resp.StatusCode, resp.RequestID())
+	}
+}
+
+func TestClient_DoTypedErrors(t *testing.T) {
+	client, mux, cleanup := setupTest()
+	defer cleanup()
+	mux.HandleFunc("/new-endpoint", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{
+			"message": "invalid",
+			"errors":  map[string][]string{"name": {"required"}},
+		})
+	})
+
+	req, err := client.NewRequest(context.Background(), "PUT", "new-endpoint", nil, struct{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req, nil)
+	ve, ok := AsValidationError(err)
+	if !ok || len(ve.FieldErrors("name")) != 1 {
+		t.Fatalf("err = %v, want *ValidationError", err)
+	}
+	if resp == nil || resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("resp = %v", resp)
+	}
+}
+
+func TestClient_DoRaw(t *testing.T) {
+	client, mux, cleanup := setupTest()
+	defer cleanup()
+	mux.HandleFunc("/exports/traffic.csv", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Accept"); got == "application/json" {
+			t.Errorf("Accept = %q for a raw request", got)
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		io.WriteString(w, "day,bytes\n1,100\n")
+	})
+
+	req, err := client.NewRequest(context.Background(), "GET", "exports/traffic.csv", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.DoRaw(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if string(data) != "day,bytes\n1,100\n" {
+		t.Errorf("body = %q", data)
+	}
+}
+
+func TestClient_NewRequestValidation(t *testing.T) {
+	client := NewClient("key")
+	tests := []struct {
+		method, path string
+	}{
+		{"", "sub-users"},
+		{"GE T", "sub-users"},
+		{"GET", "https://evil.example.com/steal"},
+		{"GET", "//evil.example.com/steal"},
+		{"GET", "sub-users?limit=1"},
+	}
+	for _, tt := range tests {
+		if _, err := client.NewRequest(context.Background(), tt.method, tt.path, nil, nil); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("NewRequest(%q, %q) error = %v, want ErrInvalidArgument", tt.method, tt.path, err)
+		}
+	}
+	if _, err := client.NewRequest(nil, "GET", "sub-users", nil, nil); err == nil {
+		t.Error("NewRequest with nil context succeeded")
+	}
+}
@@ -0,0 +1,107 @@
+package proxyhat
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type responseKey struct{}
+
+// responseSink is stored in the context by ContextWithResponse. Its mutex
+// serializes the writes of concurrent calls sharing the context.
+type responseSink struct {
+	mu  sync.Mutex
+	dst *Response
+}
+
+// ContextWithResponse returns a context that makes API calls store their
+// response metadata in dst. Service methods only return the decoded
+// payload; use this to read headers such as the request ID:
+//
+//	var resp proxyhat.Response
+//	subUser, err := client.SubUsers.Get(proxyhat.ContextWithResponse(ctx, &resp), id)
+//	log.Printf("request %s returned %d", resp.RequestID(), resp.StatusCode)
+//
+// dst is filled in whenever a response was received, including on API
+// errors. Its body has already been read. The context may be shared by
+// concurrent calls; dst then holds the last one to finish, and must only
+// be read once they have all returned.
+func ContextWithResponse(ctx context.Context, dst *Response) context.Context {
+	return context.WithValue(ctx, responseKey{}, &responseSink{dst: dst})
+}
+
+func captureResponse(ctx context.Context, resp *Response) {
+	if resp == nil || resp.Response == nil {
+		return
+	}
+	if sink, ok := ctx.Value(responseKey{}).(*responseSink); ok && sink.dst != nil {
+		sink.mu.Lock()
+		*sink.dst = *resp
+		sink.mu.Unlock()
+	}
+}
+
+// RequestID returns the X-Request-Id the API assigned to the call. Include
+// it in support requests.
+func (r *Response) RequestID() string {
+	if r == nil || r.Response == nil {
+		return ""
+	}
+	return r.Header.Get("X-Request-Id")
+}
+
+// RateLimit returns the rate limit state reported with the response, if
+// any.
+func (r *Response) RateLimit() (RateLimitState, bool) {
+	if r == nil || r.Response == nil {
+		return RateLimitState{}, false
+	}
+	now := r.received
+	if now.IsZero() {
+		now = time.Now()
+	}
+	return parseRateLimitState(r.Header, now)
+}
+
+// Deprecation reports whether the API marked the endpoint as deprecated
+// with a Deprecation header, and since when if the header gives a date.
+// Both the "@<unix seconds>" form and the older HTTP-date and "true" forms
+// are understood.
+func (r *Response) Deprecation() (since time.Time, deprecated bool) {
+	if r == nil || r.Response == nil {
+		return time.Time{}, false
+	}
+	v := strings.TrimSpace(r.Header.Get("Deprecation"))
+	if v == "" || strings.EqualFold(v, "false") {
+		return time.Time{}, false
+	}
+	if strings.HasPrefix(v, "@") {
+		if sec, err := strconv.ParseInt(v[1:], 10, 64); err == nil {
+			return time.Unix(sec, 0), true
+		}
+	} else if t, err := http.ParseTime(v); err == nil {
+		return t, true
+	}
+	return time.Time{}, true
+}
+
+// Sunset returns when the endpoint will stop working, from the Sunset
+// header.
+func (r *Response) Sunset() (time.Time, bool) {
+	if r == nil || r.Response == nil {
+		return time.Time{}, false
+	}
+	v := r.Header.Get("Sunset")
+	if v == "" {
+		return time.Time{}, false
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
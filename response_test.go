@@ -0,0 +1,161 @@
+package proxyhat
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestContextWithResponse(t *testing.T) {
+	client, mux, cleanup := setupTest()
+	defer cleanup()
+	mux.HandleFunc("/sub-users", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req-123")
+		w.Header().Set("X-RateLimit-Limit", "100")
+		w.Header().Set("X-RateLimit-Remaining", "42")
+		w.Header().Set("X-RateLimit-Reset", "1893456000")
+		w.Header().Set("Deprecation", "@1767225600")
+		w.Header().Set("Sunset", "Fri, 01 Jan 2027 00:00:00 GMT")
+		writePayload(w, []SubUser{})
+	})
+
+	var resp Response
+	if _, err := client.SubUsers.List(ContextWithResponse(context.Background(), &resp)); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("StatusCode = %d, want 200", resp.StatusCode)
+	}
+	if got := resp.RequestID(); got != "req-123" {
+		t.Errorf("RequestID() = %q, want req-123", got)
+	}
+	rl, ok := resp.RateLimit()
+	if !ok || rl.Limit != 100 || rl.Remaining != 42 || !rl.Reset.Equal(time.Unix(1893456000, 0)) {
+		t.Errorf("RateLimit() = %+v, %v", rl, ok)
+	}
+	if since, ok := resp.Deprecation(); !ok || !since.Equal(time.Unix(1767225600, 0)) {
+		t.Errorf("Deprecation() = %v, %v", since, ok)
+	}
+	want := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	if sunset, ok := resp.Sunset(); !ok || !sunset.Equal(want) {
+		t.Errorf("Sunset() = %v, %v, want %v", sunset, ok, want)
+	}
+}
+
+func TestContextWithResponse_Error(t *testing.T) {
+	client, mux, cleanup := setupTest()
+	defer cleanup()
+	mux.HandleFunc("/sub-users/missing", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req-404")
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not found"})
+	})
+
+	var resp Response
+	_, err := client.SubUsers.Get(ContextWithResponse(context.Background(), &resp), "missing")
+	if resp.StatusCode != http.StatusNotFound || resp.RequestID() != "req-404" {
+		t.Errorf("resp = %d %q", resp.StatusCode, resp.RequestID())
+	}
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.RequestID != "req-404" {
+		t.Fatalf("err = %v, want *Error with request ID", err)
+	}
+	if got, want := err.Error(), "proxyhat: 404 Not found (request req-404)"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if _, deprecated := resp.Deprecation(); deprecated {
+		t.Error("Deprecation() reported deprecated without the header")
+	}
+}
+
+func TestContextWithResponse_Concurrent(t *testing.T) {
+	client, mux, cleanup := setupTest()
+	defer cleanup()
+	mux.HandleFunc("/sub-users", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req-1")
+		writePayload(w, []SubUser{})
+	})
+
+	var resp Response
+	ctx := ContextWithResponse(context.Background(), &resp)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.SubUsers.List(ctx); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if resp.RequestID() != "req-1" {
+		t.Errorf("RequestID() = %q", resp.RequestID())
+	}
+}
+
+func TestResponse_NoResponse(t *testing.T) {
+	var resp *Response
+	if resp.RequestID() != "" {
+		t.Error("RequestID() on nil response")
+	}
+	if _, ok := resp.RateLimit(); ok {
+		t.Error("RateLimit() on nil response")
+	}
+	if _, ok := (&Response{}).Sunset(); ok {
+		t.Error("Sunset() on empty response")
+	}
+}
+
+func TestResponse_DeprecationForms(t *testing.T) {
+	tests := []struct {
+		header     string
+		since      time.Time
+		deprecated bool
+	}{
+		{"true", time.Time{}, true},
+		{"false", time.Time{}, false},
+		{"Sun, 11 Nov 2018 23:59:59 GMT", time.Date(2018, 11, 11, 23, 59, 59, 0, time.UTC), true},
+		{"@1541980799", time.Unix(1541980799, 0), true},
+	}
+	for _, tt := range tests {
+		resp := &Response{Response: &http.Response{Header: http.Header{"Deprecation": {tt.header}}}}
+		since, deprecated := resp.Deprecation()
+		if deprecated != tt.deprecated || !since.Equal(tt.since) {
+			t.Errorf("Deprecation(%q) = %v, %v, want %v, %v", tt.header, since, deprecated, tt.since, tt.deprecated)
+		}
+	}
+}
+
+func TestClient_RateLimitState(t *testing.T) {
+	client, mux, cleanup := setupTest()
+	defer cleanup()
+	remaining := "10"
+	mux.HandleFunc("/sub-users", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "60")
+		w.Header().Set("X-RateLimit-Remaining", remaining)
+		w.Header().Set("X-RateLimit-Reset", "30")
+		writePayload(w, []SubUser{})
+	})
+
+	if st := client.RateLimitState(); !st.ObservedAt.IsZero() {
+		t.Errorf("RateLimitState() before any call = %+v", st)
+	}
+	before := time.Now()
+	if _, err := client.SubUsers.List(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	remaining = "9"
+	if _, err := client.SubUsers.List(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	st := client.RateLimitState()
+	if st.Limit != 60 || st.Remaining != 9 {
+		t.Errorf("RateLimitState() = %+v, want limit 60, remaining 9", st)
+	}
+	if st.ObservedAt.Before(before) || st.Reset.Sub(st.ObservedAt) != 30*time.Second {
+		t.Errorf("RateLimitState() times = observed %v, reset %v", st.ObservedAt, st.Reset)
+	}
+}
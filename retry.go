@@ -0,0 +1,189 @@
+package proxyhat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy controls automatic retries, enabled with WithRetryPolicy.
+//
+// Network errors and the statuses in StatusCodes are retried with jittered
+// exponential backoff. A Retry-After header on the failed response takes
+// precedence over the computed backoff; if it asks for more than MaxDelay,
+// the call fails at once with the response's error instead. Only
+// idempotent methods (GET, HEAD, OPTIONS, PUT and DELETE) are retried
+// unless a call opts in with ContextWithRetry.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry. It doubles with each
+	// further attempt, up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// StatusCodes lists the response statuses that are retried.
+	StatusCodes []int
+}
+
+// DefaultRetryPolicy is used for any RetryPolicy fields left at zero.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+	StatusCodes: []int{
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	},
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultRetryPolicy.MaxAttempts
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = DefaultRetryPolicy.BaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = DefaultRetryPolicy.MaxDelay
+	}
+	if p.StatusCodes == nil {
+		p.StatusCodes = DefaultRetryPolicy.StatusCodes
+	}
+	return p
+}
+
+// retryable reports whether a failed attempt may be retried. resp is nil
+// when no response was received.
+func (p *RetryPolicy) retryable(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if resp == nil {
+		// Network failures are retried; rate limiter waits that would
+		// overrun the deadline are not.
+		var te *TransportError
+		return errors.As(err, &te)
+	}
+	for _, code := range p.StatusCodes {
+		if resp.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the wait before the attempt following the given one.
+func (p *RetryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			return d
+		}
+	}
+	d := p.MaxDelay
+	if shift := attempt - 1; shift < 32 && p.BaseDelay<<shift < p.MaxDelay {
+		d = p.BaseDelay << shift
+	}
+	// Wait between half and all of d so that concurrent clients spread out.
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+// parseRetryAfter parses a Retry-After header value in either its
+// delay-seconds or HTTP-date form.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	if d := t.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+type retryContextKey struct{}
+
+// ContextWithRetry overrides whether calls made with the returned context
+// are retried. Use it to opt non-idempotent calls such as POST into the
+// client's retry policy, or to opt a single call out of it.
+func ContextWithRetry(ctx context.Context, enabled bool) context.Context {
+	return context.WithValue(ctx, retryContextKey{}, enabled)
+}
+
+func retryAllowed(ctx context.Context, method string) bool {
+	if enabled, ok := ctx.Value(retryContextKey{}).(bool); ok {
+		return enabled
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// RetryAttempt records one failed attempt of a retried request.
+type RetryAttempt struct {
+	// Err is the error of the attempt.
+	Err error
+	// StatusCode is the response status, or zero for network errors.
+	StatusCode int
+	// Wait is the backoff before the next attempt, or zero for the last one.
+	Wait time.Duration
+}
+
+// RetryError is returned when a request still fails after being retried.
+// It unwraps to the final error, so errors.As and the IsXError helpers see
+// through it.
+type RetryError struct {
+	Attempts []RetryAttempt
+	Err      error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("proxyhat: giving up after %d attempts: %v", len(e.Attempts), e.Err)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// retryHistoryError wraps err with the attempt history when the request was
+// actually retried.
+func retryHistoryError(history []RetryAttempt, err error) error {
+	if len(history) < 2 {
+		return err
+	}
+	return &RetryError{Attempts: history, Err: err}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
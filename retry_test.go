@@ -0,0 +1,232 @@
+package proxyhat
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// setupRetryTest returns a client with retries enabled whose backoff waits
+// are recorded instead of slept.
+func setupRetryTest(p RetryPolicy) (*Client, *http.ServeMux, *[]time.Duration, func()) {
+	client, mux, cleanup := setupTest()
+	WithRetryPolicy(p)(client)
+	var waits []time.Duration
+	client.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return ctx.Err()
+	}
+	return client, mux, &waits, cleanup
+}
+
+func TestRetry_TransientStatus(t *testing.T) {
+	client, mux, waits, cleanup := setupRetryTest(RetryPolicy{})
+	defer cleanup()
+
+	var calls atomic.Int32
+	mux.HandleFunc("/sub-users", func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"message": "busy"})
+			return
+		}
+		writePayload(w, []SubUser{{ProxyUsername: "user1"}})
+	})
+
+	users, err := client.SubUsers.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || calls.Load() != 3 {
+		t.Errorf("users = %v, calls = %d", users, calls.Load())
+	}
+	if len(*waits) != 2 {
+		t.Fatalf("waits = %v, want 2", *waits)
+	}
+	for i, w := range *waits {
+		base := DefaultRetryPolicy.BaseDelay << i
+		if w < base/2 || w > base {
+			t.Errorf("wait %d = %v, want within [%v, %v]", i, w, base/2, base)
+		}
+	}
+}
+
+func TestRetry_RetryAfter(t *testing.T) {
+	client, mux, waits, cleanup := setupRetryTest(RetryPolicy{MaxAttempts: 3, MaxDelay: 2 * time.Minute})
+	defer cleanup()
+
+	var calls atomic.Int32
+	mux.HandleFunc("/sub-users", func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			w.Header().Set("Retry-After", "7")
+			writeJSON(w, http.StatusTooManyRequests, map[string]string{"message": "slow down"})
+		case 2:
+			w.Header().Set("Retry-After", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+			writeJSON(w, http.StatusTooManyRequests, map[string]string{"message": "slow down"})
+		default:
+			writePayload(w, []SubUser{})
+		}
+	})
+
+	if _, err := client.SubUsers.List(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(*waits) != 2 || (*waits)[0] != 7*time.Second || (*waits)[1] < 58*time.Second || (*waits)[1] > time.Minute {
+		t.Errorf("waits = %v", *waits)
+	}
+}
+
+func TestRetry_RetryAfterBeyondMaxDelay(t *testing.T) {
+	client, mux, waits, cleanup := setupRetryTest(RetryPolicy{MaxDelay: time.Minute})
+	defer cleanup()
+
+	var calls atomic.Int32
+	mux.HandleFunc("/sub-users", func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "3600")
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"message": "slow down"})
+	})
+
+	_, err := client.SubUsers.List(context.Background())
+	if rle, ok := AsRateLimitError(err); !ok || rle.RetryAfter != 3600 {
+		t.Errorf("err = %v, want RateLimitError with RetryAfter 3600", err)
+	}
+	if calls.Load() != 1 || len(*waits) != 0 {
+		t.Errorf("calls = %d, waits = %v; want one call and no wait", calls.Load(), *waits)
+	}
+}
+
+func TestRetry_Exhausted(t *testing.T) {
+	client, mux, _, cleanup := setupRetryTest(RetryPolicy{MaxAttempts: 3})
+	defer cleanup()
+
+	mux.HandleFunc("/sub-users", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "1")
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"message": "slow down"})
+	})
+
+	_, err := client.SubUsers.List(context.Background())
+	var re *RetryError
+	if !errors.As(err, &re) {
+		t.Fatalf("err = %v, want *RetryError", err)
+	}
+	if len(re.Attempts) != 3 || re.Attempts[0].StatusCode != http.StatusTooManyRequests || re.Attempts[0].Wait != time.Second || re.Attempts[2].Wait != 0 {
+		t.Errorf("attempts = %+v", re.Attempts)
+	}
+	if rle, ok := AsRateLimitError(err); !ok || rle.RetryAfter != 1 {
+		t.Errorf("AsRateLimitError = %v, %v", rle, ok)
+	}
+}
+
+func TestRetry_NonRetryableStatus(t *testing.T) {
+	client, mux, waits, cleanup := setupRetryTest(RetryPolicy{})
+	defer cleanup()
+
+	mux.HandleFunc("/sub-users/missing", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "not found"})
+	})
+
+	_, err := client.SubUsers.Get(context.Background(), "missing")
+	if !IsNotFoundError(err) || len(*waits) != 0 {
+		t.Errorf("err = %v, waits = %v", err, *waits)
+	}
+	var re *RetryError
+	if errors.As(err, &re) {
+		t.Error("single attempt should not be wrapped in *RetryError")
+	}
+}
+
+func TestRetry_PostOptIn(t *testing.T) {
+	client, mux, _, cleanup := setupRetryTest(RetryPolicy{})
+	defer cleanup()
+
+	var calls atomic.Int32
+	mux.HandleFunc("/sub-users", func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			writeJSON(w, http.StatusBadGateway, map[string]string{"message": "bad gateway"})
+			return
+		}
+		writePayload(w, SubUser{ProxyUsername: "user1"})
+	})
+
+	if _, err := client.SubUsers.Create(context.Background(), CreateSubUserParams{}); err == nil {
+		t.Fatal("POST should not be retried by default")
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("calls = %d, want 1", calls.Load())
+	}
+
+	ctx := ContextWithRetry(context.Background(), true)
+	if _, err := client.SubUsers.Create(ctx, CreateSubUserParams{}); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("calls = %d, want 3", calls.Load())
+	}
+}
+
+func TestRetry_NetworkError(t *testing.T) {
+	client, mux, _, cleanup := setupRetryTest(RetryPolicy{})
+	defer cleanup()
+
+	var calls atomic.Int32
+	mux.HandleFunc("/sub-users", func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		writePayload(w, []SubUser{})
+	})
+
+	if _, err := client.SubUsers.List(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("calls = %d, want 2", calls.Load())
+	}
+}
+
+func TestRetry_ContextCanceledDuringBackoff(t *testing.T) {
+	client, mux, cleanup := setupTest()
+	defer cleanup()
+	WithRetryPolicy(RetryPolicy{BaseDelay: time.Hour, MaxDelay: time.Hour})(client)
+
+	mux.HandleFunc("/sub-users", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"message": "busy"})
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	_, err := client.SubUsers.List(ctx)
+	var re *RetryError
+	if !errors.As(err, &re) || !errors.Is(err, context.Canceled) || len(re.Attempts) != 1 {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{"120", 2 * time.Minute, true},
+		{"0", 0, true},
+		{"Fri, 02 Jan 2026 15:04:35 GMT", 30 * time.Second, true},
+		{"Fri, 02 Jan 2026 15:00:00 GMT", 0, true},
+		{"", 0, false},
+		{"-1", 0, false},
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.in, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %v; want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package proxyhat
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"syscall"
+)
+
+// BlockDetector decides whether a target blocked the current exit IP. It is
+// called with the response or the transport error of each attempt.
+type BlockDetector interface {
+	Blocked(resp *http.Response, err error) bool
+}
+
+// BlockDetectorFunc adapts a function to the BlockDetector interface.
+type BlockDetectorFunc func(resp *http.Response, err error) bool
+
+// Blocked calls f(resp, err).
+func (f BlockDetectorFunc) Blocked(resp *http.Response, err error) bool {
+	return f(resp, err)
+}
+
+// StatusCodeDetector reports responses with any of the given status codes as
+// blocked. Without codes it matches 403 and 429.
+func StatusCodeDetector(codes ...int) BlockDetector {
+	if len(codes) == 0 {
+		codes = []int{http.StatusForbidden, http.StatusTooManyRequests}
+	}
+	return BlockDetectorFunc(func(resp *http.Response, err error) bool {
+		if resp == nil {
+			return false
+		}
+		for _, code := range codes {
+			if resp.StatusCode == code {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// DefaultBlockPatterns match common captcha and block pages.
+var DefaultBlockPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)captcha`),
+	regexp.MustCompile(`(?i)unusual traffic`),
+	regexp.MustCompile(`(?i)access denied`),
+}
+
+// maxBlockBodyScan bounds how much of a response body is scanned for block patterns.
+const maxBlockBodyScan = 64 << 10
+
+// BodyPatternDetector reports non-2xx HTML responses whose body matches any
+// of the patterns as blocked. Successful responses and other content types,
+// such as streams, are passed through unread, so pages that merely embed a
+// captcha widget do not count. Without patterns it uses
+// DefaultBlockPatterns. Only the first 64 KiB are scanned and the body is
+// restored for the caller.
+func BodyPatternDetector(patterns ...*regexp.Regexp) BlockDetector {
+	if len(patterns) == 0 {
+		patterns = DefaultBlockPatterns
+	}
+	return BlockDetectorFunc(func(resp *http.Response, err error) bool {
+		if resp == nil || resp.Body == nil || resp.StatusCode/100 == 2 {
+			return false
+		}
+		if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/html" {
+			return false
+		}
+		head, readErr := io.ReadAll(io.LimitReader(resp.Body, maxBlockBodyScan))
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(head), resp.Body), resp.Body}
+		if readErr != nil {
+			return false
+		}
+		for _, p := range patterns {
+			if p.Match(head) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// ConnectionResetDetector reports connection resets and unexpected EOFs from
+// the transport as blocked.
+func ConnectionResetDetector() BlockDetector {
+	return BlockDetectorFunc(func(resp *http.Response, err error) bool {
+		if err == nil {
+			return false
+		}
+		return errors.Is(err, syscall.ECONNRESET) ||
+			errors.Is(err, io.EOF) ||
+			errors.Is(err, io.ErrUnexpectedEOF)
+	})
+}
+
+const DefaultMaxRotations = 3
+
+// RotationOption configures a RotatingTransport.
+type RotationOption func(*RotatingTransport)
+
+// WithBlockDetectors replaces the default block detectors.
+func WithBlockDetectors(detectors ...BlockDetector) RotationOption {
+	return func(t *RotatingTransport) {
+		t.detectors = detectors
+	}
+}
+
+// WithMaxRotations sets how many times a request may rotate to a new exit IP
+// before the blocked response is returned.
+func WithMaxRotations(n int) RotationOption {
+	return func(t *RotatingTransport) {
+		t.maxRotations = n
+	}
+}
+
+// WithRotationObserver sets a function called once per request with the
+// number of rotations it needed and its final error.
+func WithRotationObserver(fn func(req *http.Request, rotations int, err error)) RotationOption {
+	return func(t *RotatingTransport) {
+		t.observer = fn
+	}
+}
+
+// RotatingTransport retries requests through a fresh session when the target
+// blocks the current exit IP. The base transport must resolve sessions from
+// the same SessionManager, typically a NewProxyTransport built WithSessions.
+//
+// Requests without a session key get a temporary one for their lifetime.
+// Requests with a body are only retried if they set GetBody.
+type RotatingTransport struct {
+	base         http.RoundTripper
+	sessions     *SessionManager
+	detectors    []BlockDetector
+	maxRotations int
+	observer     func(req *http.Request, rotations int, err error)
+}
+
+// NewRotatingTransport wraps base with block detection and session rotation.
+func NewRotatingTransport(base http.RoundTripper, sessions *SessionManager, opts ...RotationOption) *RotatingTransport {
+	t := &RotatingTransport{
+		base:     base,
+		sessions: sessions,
+		detectors: []BlockDetector{
+			StatusCodeDetector(),
+			BodyPatternDetector(),
+			ConnectionResetDetector(),
+		},
+		maxRotations: DefaultMaxRotations,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+type rotationsContextKey struct{}
+
+// RotationCount returns how many rotations the RotatingTransport needed to
+// produce resp.
+func RotationCount(resp *http.Response) int {
+	if resp == nil || resp.Request == nil {
+		return 0
+	}
+	n, _ := resp.Request.Context().Value(rotationsContextKey{}).(int)
+	return n
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *RotatingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	key, ok := SessionKeyFromContext(ctx)
+	if !ok {
+		key = "rotate-" + randomSessionID()
+		ctx = ContextWithSessionKey(ctx, key)
+		defer t.sessions.Remove(key)
+	}
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	for rotations := 0; ; rotations++ {
+		attempt := req.WithContext(context.WithValue(ctx, rotationsContextKey{}, rotations))
+		if rotations > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				t.observe(req, rotations, err)
+				return nil, err
+			}
+			attempt.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(attempt)
+		if !t.blocked(resp, err) {
+			if err == nil {
+				t.sessions.ReportSuccess(key)
+			}
+			t.observe(req, rotations, err)
+			return resp, err
+		}
+
+		if rotations >= t.maxRotations || !replayable || ctx.Err() != nil {
+			t.sessions.ReportFailure(key)
+			t.observe(req, rotations, err)
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+		t.sessions.Rotate(key)
+	}
+}
+
+func (t *RotatingTransport) blocked(resp *http.Response, err error) bool {
+	for _, d := range t.detectors {
+		if d.Blocked(resp, err) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *RotatingTransport) observe(req *http.Request, rotations int, err error) {
+	if t.observer != nil {
+		t.observer(req, rotations, err)
+	}
+}
@@ -0,0 +1,163 @@
+package proxyhat
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func newTestRotatingClient(t *testing.T, gw *testGateway, opts ...RotationOption) (*http.Client, *SessionManager) {
+	t.Helper()
+	m, _ := newTestSessionManager()
+	base, err := NewProxyTransport(&SubUser{ProxyUsername: "user1"}, "secret", Targeting{},
+		WithGateway(gw.gateway()), WithSessions(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Client{Transport: NewRotatingTransport(base, m, opts...)}, m
+}
+
+func TestRotatingTransport_RotatesUntilUnblocked(t *testing.T) {
+	gw := newTestGateway(t, "secret")
+	var hits int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "payload" {
+			t.Errorf("body = %q, want %q", body, "payload")
+		}
+		switch atomic.AddInt32(&hits, 1) {
+		case 1:
+			w.WriteHeader(http.StatusForbidden)
+		case 2:
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			io.WriteString(w, "<html>Please solve this CAPTCHA</html>")
+		default:
+			io.WriteString(w, "ok")
+		}
+	}))
+	defer target.Close()
+
+	var observed int
+	client, _ := newTestRotatingClient(t, gw, WithRotationObserver(func(_ *http.Request, n int, _ error) {
+		observed = n
+	}))
+	req, _ := http.NewRequest("POST", target.URL, bytes.NewReader([]byte("payload")))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "ok" {
+		t.Errorf("body = %q, want %q", body, "ok")
+	}
+	if got := RotationCount(resp); got != 2 {
+		t.Errorf("RotationCount = %d, want 2", got)
+	}
+	if observed != 2 {
+		t.Errorf("observed rotations = %d, want 2", observed)
+	}
+
+	seen := gw.seen()
+	if len(seen) != 3 || seen[0] == seen[1] || seen[1] == seen[2] {
+		t.Errorf("expected three distinct sessions, got %v", seen)
+	}
+}
+
+func TestRotatingTransport_GivesUpAfterMaxRotations(t *testing.T) {
+	gw := newTestGateway(t, "secret")
+	var hits int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer target.Close()
+
+	client, _ := newTestRotatingClient(t, gw, WithMaxRotations(1))
+	resp, err := client.Get(target.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429", resp.StatusCode)
+	}
+	if got := atomic.LoadInt32(&hits); got != 2 {
+		t.Errorf("hits = %d, want 2", got)
+	}
+}
+
+func TestRotatingTransport_KeepsSessionKey(t *testing.T) {
+	gw := newTestGateway(t, "secret")
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer target.Close()
+
+	client, m := newTestRotatingClient(t, gw, WithMaxRotations(1))
+	before := m.Session("account-42")
+	req, _ := http.NewRequestWithContext(ContextWithSessionKey(context.Background(), "account-42"), "GET", target.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if after := m.Session("account-42"); after.ID == before.ID {
+		t.Error("pinned session was not rotated")
+	}
+}
+
+func TestBodyPatternDetector_OnlyErrorPages(t *testing.T) {
+	page := "<html><script src=\"https://www.google.com/recaptcha/api.js\"></script></html>"
+	tests := []struct {
+		status      int
+		contentType string
+		want        bool
+	}{
+		{http.StatusOK, "text/html", false},
+		{http.StatusForbidden, "text/html; charset=utf-8", true},
+		{http.StatusForbidden, "application/json", false},
+		{http.StatusServiceUnavailable, "", false},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{
+			StatusCode: tt.status,
+			Header:     http.Header{"Content-Type": {tt.contentType}},
+			Body:       io.NopCloser(strings.NewReader(page)),
+		}
+		if got := BodyPatternDetector().Blocked(resp, nil); got != tt.want {
+			t.Errorf("Blocked(%d, %q) = %v, want %v", tt.status, tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestBodyPatternDetector_RestoresBody(t *testing.T) {
+	resp := &http.Response{
+		StatusCode: http.StatusForbidden,
+		Header:     http.Header{"Content-Type": {"text/html"}},
+		Body:       io.NopCloser(strings.NewReader("all good")),
+	}
+	if BodyPatternDetector().Blocked(resp, nil) {
+		t.Error("unexpected block")
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "all good" {
+		t.Errorf("body = %q, want %q", body, "all good")
+	}
+}
+
+func TestConnectionResetDetector(t *testing.T) {
+	d := ConnectionResetDetector()
+	if !d.Blocked(nil, io.ErrUnexpectedEOF) {
+		t.Error("expected unexpected EOF to be blocked")
+	}
+	if d.Blocked(&http.Response{StatusCode: 200}, nil) {
+		t.Error("expected success not to be blocked")
+	}
+}
@@ -0,0 +1,262 @@
+package proxyhat
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Match types for routing rules.
+const (
+	MatchExact  = "exact"
+	MatchGlob   = "glob"
+	MatchSuffix = "suffix"
+	MatchRegexp = "regexp"
+	MatchCIDR   = "cidr"
+)
+
+// Route is what a routing rule resolves a destination host to: either a
+// direct connection or the gateway with the given targeting.
+type Route struct {
+	Direct    bool      `json:"direct,omitempty"`
+	Targeting Targeting `json:"targeting"`
+}
+
+// RoutingRule routes destination hosts matching Pattern. Match is one of
+// MatchExact, MatchGlob, MatchSuffix, MatchRegexp or MatchCIDR. Host names
+// are matched case-insensitively; CIDR rules only match literal IP hosts.
+// Regexp patterns are limited to the syntax Go and JavaScript share, as
+// WritePAC copies them into the PAC file.
+type RoutingRule struct {
+	Match   string `json:"match"`
+	Pattern string `json:"pattern"`
+	Route
+}
+
+// RoutingRules is an ordered rule list, usually loaded from a JSON file:
+//
+//	{
+//	  "rules": [
+//	    {"match": "suffix", "pattern": "de", "targeting": {"country": "DE", "connection_type": "residential"}},
+//	    {"match": "exact", "pattern": "api.internal", "direct": true},
+//	    {"match": "cidr", "pattern": "10.0.0.0/8", "direct": true}
+//	  ],
+//	  "default": {"targeting": {"country": "US", "connection_type": "mobile"}}
+//	}
+type RoutingRules struct {
+	Rules []RoutingRule `json:"rules"`
+	// Default applies when no rule matches. If nil, the transport's own
+	// targeting is used.
+	Default *Route `json:"default,omitempty"`
+}
+
+type compiledRule struct {
+	RoutingRule
+	re  *regexp.Regexp
+	net *net.IPNet
+}
+
+func (r *compiledRule) matches(host string) bool {
+	switch r.Match {
+	case MatchExact:
+		return host == r.Pattern
+	case MatchGlob:
+		ok, _ := path.Match(r.Pattern, host)
+		return ok
+	case MatchSuffix:
+		suffix := strings.TrimPrefix(r.Pattern, ".")
+		return host == suffix || strings.HasSuffix(host, "."+suffix)
+	case MatchRegexp:
+		return r.re.MatchString(host)
+	case MatchCIDR:
+		ip := net.ParseIP(host)
+		return ip != nil && r.net.Contains(ip)
+	}
+	return false
+}
+
+// Router picks a Route per destination host by evaluating rules in order.
+// It is safe for concurrent use.
+type Router struct {
+	rules []compiledRule
+	def   *Route
+}
+
+// NewRouter compiles the rules.
+func NewRouter(rules RoutingRules) (*Router, error) {
+	r := &Router{def: rules.Default}
+	if r.def != nil && !r.def.Direct {
+		if err := r.def.Targeting.Validate(); err != nil {
+			return nil, fmt.Errorf("proxyhat: default route: %w", err)
+		}
+	}
+	for i, rule := range rules.Rules {
+		c := compiledRule{RoutingRule: rule}
+		c.Pattern = strings.ToLower(rule.Pattern)
+		switch rule.Match {
+		case MatchExact, MatchSuffix:
+		case MatchGlob:
+			if _, err := path.Match(c.Pattern, ""); err != nil {
+				return nil, fmt.Errorf("proxyhat: rule %d: invalid glob %q: %w", i, rule.Pattern, err)
+			}
+		case MatchRegexp:
+			// Hosts are lower-cased before matching.
+			re, err := regexp.Compile("(?i)" + rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("proxyhat: rule %d: %w", i, err)
+			}
+			if s := jsUnsupported(rule.Pattern); s != "" {
+				return nil, fmt.Errorf("proxyhat: rule %d: regexp %q uses %q, which PAC files do not support", i, rule.Pattern, s)
+			}
+			c.Pattern = rule.Pattern
+			c.re = re
+		case MatchCIDR:
+			_, ipNet, err := net.ParseCIDR(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("proxyhat: rule %d: %w", i, err)
+			}
+			c.net = ipNet
+		default:
+			return nil, fmt.Errorf("proxyhat: rule %d: unknown match type %q", i, rule.Match)
+		}
+		if !rule.Direct {
+			if err := rule.Targeting.Validate(); err != nil {
+				return nil, fmt.Errorf("proxyhat: rule %d: %w", i, err)
+			}
+		}
+		r.rules = append(r.rules, c)
+	}
+	return r, nil
+}
+
+// LoadRouter compiles the rules in a JSON file.
+func LoadRouter(path string) (*Router, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules RoutingRules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("proxyhat: invalid routing rules %s: %w", path, err)
+	}
+	return NewRouter(rules)
+}
+
+// Route returns the route for host. The second result is false when no rule
+// matches and there is no default route.
+func (r *Router) Route(host string) (Route, bool) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for i := range r.rules {
+		if r.rules[i].matches(host) {
+			return r.rules[i].Route, true
+		}
+	}
+	if r.def != nil {
+		return *r.def, true
+	}
+	return Route{}, false
+}
+
+// WithRouter makes the proxy transport pick the targeting for each request
+// from the router. Direct routes bypass the gateway; hosts without a route
+// use the transport's own targeting.
+func WithRouter(r *Router) GatewayOption {
+	return func(c *gatewayConfig) {
+		c.router = r
+	}
+}
+
+// WritePAC writes the rules as a proxy auto-config file. proxyFor returns
+// the PAC result for a gateway route, e.g. "PROXY 127.0.0.1:8888" for a
+// local forwarder; direct routes always produce "DIRECT". When there is no
+// default route, proxyFor is called with the zero Route for the fallback.
+func (r *Router) WritePAC(w io.Writer, proxyFor func(Route) string) error {
+	var b strings.Builder
+	b.WriteString("function FindProxyForURL(url, host) {\n")
+	b.WriteString("  host = host.toLowerCase();\n")
+	for i := range r.rules {
+		rule := &r.rules[i]
+		fmt.Fprintf(&b, "  if (%s) return %s;\n", rule.pacCondition(), pacResult(rule.Route, proxyFor))
+	}
+	def := Route{}
+	if r.def != nil {
+		def = *r.def
+	}
+	fmt.Fprintf(&b, "  return %s;\n", pacResult(def, proxyFor))
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// jsUnsupported returns the first construct of a Go regexp that
+// JavaScript, and so a PAC file, does not parse the same way, or "" if
+// there is none: flags and named groups, \A, \z, \Q...\E, Unicode
+// classes, octal and \x{...} escapes, POSIX classes and a leading ']' in
+// a character class.
+func jsUnsupported(pattern string) string {
+	inClass := false
+	for i := 0; i < len(pattern); i++ {
+		rest := pattern[i+1:]
+		switch c := pattern[i]; {
+		case c == '\\' && rest != "":
+			if strings.IndexByte("AzQEpP0123456789", rest[0]) >= 0 || strings.HasPrefix(rest, "x{") {
+				return pattern[i : i+2]
+			}
+			i++
+		case c == '[' && inClass:
+			if strings.HasPrefix(rest, ":") {
+				return "[:"
+			}
+		case c == '[':
+			inClass = true
+			if strings.HasPrefix(strings.TrimPrefix(rest, "^"), "]") {
+				// A literal ']' in Go, an empty class in JavaScript.
+				return "[]"
+			}
+		case c == ']' && inClass:
+			inClass = false
+		case c == '(' && strings.HasPrefix(rest, "?") && !strings.HasPrefix(rest, "?:") && !inClass:
+			if j := strings.IndexAny(rest[1:], ":)<"); j >= 0 {
+				return pattern[i : i+j+3]
+			}
+			return pattern[i:]
+		}
+	}
+	return ""
+}
+
+func pacResult(route Route, proxyFor func(Route) string) string {
+	if route.Direct {
+		return strconv.Quote("DIRECT")
+	}
+	return strconv.Quote(proxyFor(route))
+}
+
+func (r *compiledRule) pacCondition() string {
+	switch r.Match {
+	case MatchExact:
+		return "host == " + strconv.Quote(r.Pattern)
+	case MatchGlob:
+		return "shExpMatch(host, " + strconv.Quote(r.Pattern) + ")"
+	case MatchSuffix:
+		suffix := strings.TrimPrefix(r.Pattern, ".")
+		return "host == " + strconv.Quote(suffix) + " || dnsDomainIs(host, " + strconv.Quote("."+suffix) + ")"
+	case MatchRegexp:
+		return "/" + strings.ReplaceAll(r.Pattern, "/", `\/`) + "/i.test(host)"
+	case MatchCIDR:
+		// isInNet resolves host names, but the Router only matches literal
+		// IPs, so the condition is guarded by a literal check.
+		if ip4 := r.net.IP.To4(); ip4 != nil {
+			mask := net.IP(r.net.Mask).String()
+			return `/^\d+\.\d+\.\d+\.\d+$/.test(host) && isInNet(host, ` + strconv.Quote(ip4.String()) + ", " + strconv.Quote(mask) + ")"
+		}
+		return `host.indexOf(":") >= 0 && isInNetEx(host, ` + strconv.Quote(r.net.String()) + ")"
+	}
+	return "false"
+}
@@ -0,0 +1,183 @@
+package proxyhat
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testRoutingRules() RoutingRules {
+	return RoutingRules{
+		Rules: []RoutingRule{
+			{Match: MatchExact, Pattern: "api.internal", Route: Route{Direct: true}},
+			{Match: MatchCIDR, Pattern: "10.0.0.0/8", Route: Route{Direct: true}},
+			{Match: MatchSuffix, Pattern: ".de", Route: Route{Targeting: Targeting{Country: "DE", ConnectionType: "residential"}}},
+			{Match: MatchGlob, Pattern: "*.shop.*", Route: Route{Targeting: Targeting{Country: "GB"}}},
+			{Match: MatchRegexp, Pattern: `^IMG[0-9]+\.`, Route: Route{Targeting: Targeting{Country: "FR"}}},
+		},
+		Default: &Route{Targeting: Targeting{Country: "US", ConnectionType: "mobile"}},
+	}
+}
+
+func TestRouter_Route(t *testing.T) {
+	r, err := NewRouter(testRoutingRules())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		host    string
+		direct  bool
+		country string
+	}{
+		{"api.internal", true, ""},
+		{"API.Internal.", true, ""},
+		{"10.1.2.3", true, ""},
+		{"example.de", false, "DE"},
+		{"de", false, "DE"},
+		{"node.de.example.com", false, "US"},
+		{"www.shop.com", false, "GB"},
+		{"img12.example.com", false, "FR"},
+		{"11.0.0.1", false, "US"},
+	}
+	for _, tt := range tests {
+		route, ok := r.Route(tt.host)
+		if !ok {
+			t.Errorf("Route(%q) not found", tt.host)
+			continue
+		}
+		if route.Direct != tt.direct || route.Targeting.Country != tt.country {
+			t.Errorf("Route(%q) = %+v, want direct=%v country=%q", tt.host, route, tt.direct, tt.country)
+		}
+	}
+}
+
+func TestRouter_NoDefault(t *testing.T) {
+	r, err := NewRouter(RoutingRules{Rules: []RoutingRule{{Match: MatchSuffix, Pattern: "de"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.Route("example.com"); ok {
+		t.Error("expected no route without a default")
+	}
+}
+
+func TestNewRouter_Invalid(t *testing.T) {
+	invalid := []RoutingRule{
+		{Match: "prefix", Pattern: "x"},
+		{Match: MatchRegexp, Pattern: "("},
+		{Match: MatchRegexp, Pattern: `(?i)^img\.`},
+		{Match: MatchRegexp, Pattern: `(?P<sub>[a-z]+)\.example\.com`},
+		{Match: MatchRegexp, Pattern: `\Aexample\.com\z`},
+		{Match: MatchRegexp, Pattern: `^[[:alpha:]]+\.de$`},
+		{Match: MatchRegexp, Pattern: `^\pL+\.de$`},
+		{Match: MatchCIDR, Pattern: "10.0.0.0"},
+		{Match: MatchGlob, Pattern: "[x"},
+		{Match: MatchExact, Pattern: "x", Route: Route{Targeting: Targeting{Country: "u-s"}}},
+	}
+	for _, rule := range invalid {
+		if _, err := NewRouter(RoutingRules{Rules: []RoutingRule{rule}}); err == nil {
+			t.Errorf("NewRouter(%+v) = nil error", rule)
+		}
+	}
+}
+
+func TestNewRouter_GoOnlyRegexp(t *testing.T) {
+	// Matching is already case-insensitive, and a PAC file cannot carry
+	// Go's inline flags.
+	_, err := NewRouter(RoutingRules{Rules: []RoutingRule{{Match: MatchRegexp, Pattern: `(?i)^img\.`}}})
+	if err == nil || !strings.Contains(err.Error(), `"(?i)"`) {
+		t.Errorf("err = %v, want it to name (?i)", err)
+	}
+	if _, err := NewRouter(RoutingRules{Rules: []RoutingRule{{Match: MatchRegexp, Pattern: `^(?:img|cdn)[0-9]*\.[a-z]+$`}}}); err != nil {
+		t.Errorf("shared syntax rejected: %v", err)
+	}
+}
+
+func TestLoadRouter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	data := `{
+		"rules": [{"match": "suffix", "pattern": "de", "targeting": {"country": "DE", "session_ttl": "5m", "session": "s1"}}],
+		"default": {"direct": true}
+	}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r, err := LoadRouter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if route, _ := r.Route("example.de"); route.Targeting.Country != "DE" || route.Targeting.SessionTTL == 0 {
+		t.Errorf("Route(example.de) = %+v", route)
+	}
+	if route, _ := r.Route("example.com"); !route.Direct {
+		t.Errorf("Route(example.com) = %+v, want direct", route)
+	}
+}
+
+func TestRouter_WritePAC(t *testing.T) {
+	r, err := NewRouter(testRoutingRules())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	err = r.WritePAC(&b, func(route Route) string {
+		return "PROXY " + strings.ToLower(route.Targeting.Country) + ".proxy.local:8080"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pac := b.String()
+	for _, want := range []string{
+		"function FindProxyForURL(url, host) {",
+		`if (host == "api.internal") return "DIRECT";`,
+		`if (/^\d+\.\d+\.\d+\.\d+$/.test(host) && isInNet(host, "10.0.0.0", "255.0.0.0")) return "DIRECT";`,
+		`if (host == "de" || dnsDomainIs(host, ".de")) return "PROXY de.proxy.local:8080";`,
+		`if (shExpMatch(host, "*.shop.*")) return "PROXY gb.proxy.local:8080";`,
+		`if (/^IMG[0-9]+\./i.test(host)) return "PROXY fr.proxy.local:8080";`,
+		`return "PROXY us.proxy.local:8080";`,
+	} {
+		if !strings.Contains(pac, want) {
+			t.Errorf("PAC missing %q:\n%s", want, pac)
+		}
+	}
+}
+
+func TestNewProxyTransport_WithRouter(t *testing.T) {
+	gw := newTestGateway(t, "secret")
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer target.Close()
+
+	r, err := NewRouter(RoutingRules{Rules: []RoutingRule{
+		{Match: MatchExact, Pattern: "localhost", Route: Route{Targeting: Targeting{Country: "DE"}}},
+		{Match: MatchCIDR, Pattern: "127.0.0.0/8", Route: Route{Direct: true}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport, err := NewProxyTransport(&SubUser{ProxyUsername: "user1"}, "secret", Targeting{Country: "US"},
+		WithGateway(gw.gateway()), WithRouter(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: transport}
+
+	port := target.URL[strings.LastIndex(target.URL, ":"):]
+	for _, u := range []string{target.URL, "http://localhost" + port} {
+		resp, err := client.Get(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	// The 127.0.0.1 request went direct; only the localhost one hit the gateway.
+	if got := gw.seen(); len(got) != 1 || got[0] != "user1-country-de" {
+		t.Errorf("gateway usernames = %v", got)
+	}
+}
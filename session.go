@@ -0,0 +1,210 @@
+package proxyhat
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+const DefaultSessionTTL = 10 * time.Minute
+
+// Session is a sticky gateway session pinned to a logical key.
+type Session struct {
+	Key       string
+	ID        string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	Failures  int
+	// Rotations counts how many times the key has moved to a new session.
+	Rotations int
+}
+
+// SessionOption configures a SessionManager.
+type SessionOption func(*SessionManager)
+
+// WithSessionTTL sets how long a session stays sticky before it rotates.
+func WithSessionTTL(d time.Duration) SessionOption {
+	return func(m *SessionManager) {
+		m.ttl = d
+	}
+}
+
+// WithMaxFailures rotates a session after n reported failures. Zero disables
+// failure-based rotation.
+func WithMaxFailures(n int) SessionOption {
+	return func(m *SessionManager) {
+		m.maxFailures = n
+	}
+}
+
+// WithSessionIDGenerator sets the function used to generate session IDs.
+// IDs must only contain lower-case letters, digits and underscores.
+func WithSessionIDGenerator(fn func() string) SessionOption {
+	return func(m *SessionManager) {
+		m.newID = fn
+	}
+}
+
+// SessionManager hands out sticky session IDs for the gateway username and
+// rotates them on expiry, on failure or on demand. Each logical key, such as
+// "account-42", keeps its own session so that all of its traffic leaves from
+// the same exit IP. It is safe for concurrent use.
+type SessionManager struct {
+	ttl         time.Duration
+	maxFailures int
+	newID       func() string
+	now         func() time.Time
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+	// nextPrune is when Session next removes idle sessions.
+	nextPrune time.Time
+}
+
+// NewSessionManager creates a new SessionManager.
+func NewSessionManager(opts ...SessionOption) *SessionManager {
+	m := &SessionManager{
+		ttl:      DefaultSessionTTL,
+		newID:    randomSessionID,
+		now:      time.Now,
+		sessions: make(map[string]*Session),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+func randomSessionID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic("proxyhat: failed to generate session ID: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+// Session returns the current session for key, starting a new one if none
+// exists or the previous one has expired.
+func (m *SessionManager) Session(key string) Session {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune()
+	s, ok := m.sessions[key]
+	if !ok {
+		s = &Session{Key: key}
+		m.sessions[key] = s
+		m.renew(s)
+	} else if !m.now().Before(s.ExpiresAt) {
+		m.rotate(s)
+	}
+	return *s
+}
+
+// Rotate moves key to a new session immediately.
+func (m *SessionManager) Rotate(key string) Session {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[key]
+	if !ok {
+		s = &Session{Key: key}
+		m.sessions[key] = s
+		m.renew(s)
+		return *s
+	}
+	m.rotate(s)
+	return *s
+}
+
+// ReportFailure records a failed request for key and reports whether the
+// session was rotated as a result.
+func (m *SessionManager) ReportFailure(key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[key]
+	if !ok {
+		return false
+	}
+	s.Failures++
+	if m.maxFailures > 0 && s.Failures >= m.maxFailures {
+		m.rotate(s)
+		return true
+	}
+	return false
+}
+
+// ReportSuccess resets the failure count for key.
+func (m *SessionManager) ReportSuccess(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.sessions[key]; ok {
+		s.Failures = 0
+	}
+}
+
+// Remove forgets the session for key.
+func (m *SessionManager) Remove(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, key)
+}
+
+// Targeting returns base pinned to the current session for key.
+func (m *SessionManager) Targeting(key string, base Targeting) Targeting {
+	s := m.Session(key)
+	return base.WithSession(s.ID, m.ttl)
+}
+
+// prune removes, at most once per TTL, the sessions that expired more than
+// a TTL ago, so that short-lived keys such as per-job ones do not
+// accumulate. Recently expired sessions are kept so that their key rotates
+// with its Rotations count intact.
+func (m *SessionManager) prune() {
+	now := m.now()
+	if now.Before(m.nextPrune) {
+		return
+	}
+	m.nextPrune = now.Add(m.ttl)
+	for key, s := range m.sessions {
+		if !now.Before(s.ExpiresAt.Add(m.ttl)) {
+			delete(m.sessions, key)
+		}
+	}
+}
+
+func (m *SessionManager) rotate(s *Session) {
+	s.Rotations++
+	m.renew(s)
+}
+
+func (m *SessionManager) renew(s *Session) {
+	now := m.now()
+	s.ID = m.newID()
+	s.CreatedAt = now
+	s.ExpiresAt = now.Add(m.ttl)
+	s.Failures = 0
+}
+
+type sessionKeyContextKey struct{}
+
+// ContextWithSessionKey returns a copy of ctx that pins requests made with it
+// to the session for key when the transport uses a SessionManager.
+func ContextWithSessionKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, sessionKeyContextKey{}, key)
+}
+
+// SessionKeyFromContext returns the session key stored in ctx, if any.
+func SessionKeyFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(sessionKeyContextKey{}).(string)
+	return key, ok
+}
+
+// WithSessions makes the proxy transport pin each request to the session of
+// the key set with ContextWithSessionKey. Requests without a key use the
+// base targeting unchanged.
+func WithSessions(m *SessionManager) GatewayOption {
+	return func(c *gatewayConfig) {
+		c.sessions = m
+	}
+}
@@ -0,0 +1,150 @@
+package proxyhat
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestSessionManager(opts ...SessionOption) (*SessionManager, *time.Time) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	n := 0
+	opts = append([]SessionOption{WithSessionIDGenerator(func() string {
+		n++
+		return fmt.Sprintf("s%d", n)
+	})}, opts...)
+	m := NewSessionManager(opts...)
+	m.now = func() time.Time { return now }
+	return m, &now
+}
+
+func TestSessionManager_Sticky(t *testing.T) {
+	m, _ := newTestSessionManager()
+	a := m.Session("account-42")
+	b := m.Session("account-42")
+	if a.ID != b.ID {
+		t.Errorf("session changed: %q != %q", a.ID, b.ID)
+	}
+	if other := m.Session("account-7"); other.ID == a.ID {
+		t.Error("different keys share a session")
+	}
+}
+
+func TestSessionManager_Expiry(t *testing.T) {
+	m, now := newTestSessionManager(WithSessionTTL(time.Minute))
+	first := m.Session("k")
+	*now = now.Add(time.Minute)
+	second := m.Session("k")
+	if second.ID == first.ID {
+		t.Error("session did not rotate on expiry")
+	}
+	if second.Rotations != 1 {
+		t.Errorf("Rotations = %d, want 1", second.Rotations)
+	}
+}
+
+func TestSessionManager_PrunesIdleSessions(t *testing.T) {
+	m, now := newTestSessionManager(WithSessionTTL(time.Minute))
+	for i := 0; i < 100; i++ {
+		m.Session(fmt.Sprintf("job-%d", i))
+	}
+	*now = now.Add(2 * time.Minute)
+	m.Session("live")
+	if n := len(m.sessions); n != 1 {
+		t.Errorf("%d sessions after pruning, want 1", n)
+	}
+}
+
+func TestSessionManager_RotateOnFailures(t *testing.T) {
+	m, _ := newTestSessionManager(WithMaxFailures(2))
+	first := m.Session("k")
+	if m.ReportFailure("k") {
+		t.Error("rotated after one failure")
+	}
+	m.ReportSuccess("k")
+	if m.ReportFailure("k") {
+		t.Error("failure count not reset by success")
+	}
+	if !m.ReportFailure("k") {
+		t.Error("did not rotate after two failures")
+	}
+	if m.Session("k").ID == first.ID {
+		t.Error("session ID unchanged after rotation")
+	}
+}
+
+func TestSessionManager_Rotate(t *testing.T) {
+	m, _ := newTestSessionManager()
+	first := m.Session("k")
+	if rotated := m.Rotate("k"); rotated.ID == first.ID {
+		t.Error("Rotate did not change session ID")
+	}
+	m.Remove("k")
+	if m.Session("k").Rotations != 0 {
+		t.Error("Remove did not reset the session")
+	}
+}
+
+func TestSessionManager_Targeting(t *testing.T) {
+	m, _ := newTestSessionManager(WithSessionTTL(5 * time.Minute))
+	got := m.Targeting("k", Targeting{Country: "US"})
+	want := Targeting{Country: "US", Session: "s1", SessionTTL: 5 * time.Minute}
+	if got != want {
+		t.Errorf("Targeting() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSessionManager_Concurrent(t *testing.T) {
+	m := NewSessionManager(WithMaxFailures(3))
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprintf("k%d", i%5)
+			m.Session(key)
+			m.ReportFailure(key)
+			m.Targeting(key, Targeting{})
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestNewProxyTransport_WithSessions(t *testing.T) {
+	gw := newTestGateway(t, "secret")
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer target.Close()
+
+	m, _ := newTestSessionManager()
+	transport, err := NewProxyTransport(&SubUser{ProxyUsername: "user1"}, "secret", Targeting{Country: "US"},
+		WithGateway(gw.gateway()), WithSessions(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: transport}
+
+	get := func(ctx context.Context) {
+		req, _ := http.NewRequestWithContext(ctx, "GET", target.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	get(ContextWithSessionKey(context.Background(), "account-42"))
+	get(context.Background())
+
+	got := gw.seen()
+	want := []string{"user1-country-us-session-s1-sesstime-10", "user1-country-us"}
+	if len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("gateway usernames = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,247 @@
+package proxyhat
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// SOCKS5 protocol constants (RFC 1928 and RFC 1929).
+const (
+	socks5Version         = 0x05
+	socks5AuthUserPass    = 0x02
+	socks5UserPassVersion = 0x01
+	socks5CmdConnect      = 0x01
+	socks5AddrIPv4        = 0x01
+	socks5AddrDomain      = 0x03
+	socks5AddrIPv6        = 0x04
+)
+
+var socks5Replies = map[byte]string{
+	0x01: "general SOCKS server failure",
+	0x02: "connection not allowed by ruleset",
+	0x03: "network unreachable",
+	0x04: "host unreachable",
+	0x05: "connection refused",
+	0x06: "TTL expired",
+	0x07: "command not supported",
+	0x08: "address type not supported",
+}
+
+// SOCKS5Error is returned when the gateway rejects a SOCKS5 CONNECT request.
+type SOCKS5Error struct {
+	Reply byte
+}
+
+func (e *SOCKS5Error) Error() string {
+	if msg, ok := socks5Replies[e.Reply]; ok {
+		return "proxyhat: socks5: " + msg
+	}
+	return fmt.Sprintf("proxyhat: socks5: unknown reply code %d", e.Reply)
+}
+
+// WithLocalDNS makes the SOCKS5 dialer resolve target host names locally and
+// send IP addresses to the gateway. By default names are resolved by the
+// gateway (socks5h semantics). A nil resolver uses net.DefaultResolver.
+func WithLocalDNS(resolver *net.Resolver) GatewayOption {
+	return func(c *gatewayConfig) {
+		if resolver == nil {
+			resolver = net.DefaultResolver
+		}
+		c.resolver = resolver
+	}
+}
+
+// SOCKS5Dialer dials TCP connections through the ProxyHat SOCKS5 gateway.
+// It is safe for concurrent use.
+type SOCKS5Dialer struct {
+	endpoint *ProxyEndpoint
+	dialer   *net.Dialer
+	resolver *net.Resolver
+}
+
+// NewSOCKS5Dialer returns a dialer that authenticates with the gateway as
+// the given sub-user.
+//
+//	dialer, err := proxyhat.NewSOCKS5Dialer(subUser, "proxy-pass", proxyhat.Targeting{Country: "US"})
+//	conn, err := dialer.DialContext(ctx, "tcp", "db.example.com:5432")
+func NewSOCKS5Dialer(subUser *SubUser, password string, t Targeting, opts ...GatewayOption) (*SOCKS5Dialer, error) {
+	cfg := newGatewayConfig(opts)
+	endpoint, err := cfg.gateway.SOCKS5Endpoint(subUser, password, t)
+	if err != nil {
+		return nil, err
+	}
+	if len(endpoint.Username) > 255 || len(endpoint.Password) > 255 {
+		return nil, errors.New("proxyhat: socks5: username and password must be at most 255 bytes")
+	}
+	return &SOCKS5Dialer{
+		endpoint: endpoint,
+		dialer:   &net.Dialer{Timeout: cfg.dialTimeout, KeepAlive: 30 * time.Second},
+		resolver: cfg.resolver,
+	}, nil
+}
+
+// Dial connects to addr through the gateway.
+func (d *SOCKS5Dialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+// DialContext connects to addr through the gateway. Only TCP networks are
+// supported.
+func (d *SOCKS5Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("proxyhat: socks5: unsupported network %q", network)
+	}
+
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("proxyhat: socks5: %w", err)
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("proxyhat: socks5: invalid port %q", portStr)
+	}
+	if d.resolver != nil && net.ParseIP(host) == nil {
+		ips, err := d.resolver.LookupIP(ctx, ipNetwork(network), host)
+		if err != nil {
+			return nil, fmt.Errorf("proxyhat: socks5: %w", err)
+		}
+		host = ips[0].String()
+	}
+
+	conn, err := d.dialer.DialContext(ctx, "tcp", d.endpoint.Addr())
+	if err != nil {
+		return nil, fmt.Errorf("proxyhat: socks5: dial gateway: %w", err)
+	}
+	if err := d.handshake(ctx, conn, host, uint16(port)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func ipNetwork(network string) string {
+	switch network {
+	case "tcp4":
+		return "ip4"
+	case "tcp6":
+		return "ip6"
+	}
+	return "ip"
+}
+
+// handshake runs the greeting, authentication and CONNECT exchange. The
+// context bounds the whole exchange by moving the connection deadline.
+func (d *SOCKS5Dialer) handshake(ctx context.Context, conn net.Conn, host string, port uint16) (err error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+	defer func() {
+		close(done)
+		<-stopped
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				err = ctxErr
+			} else if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+				// The connection deadline can fire before the context timer.
+				err = context.DeadlineExceeded
+			}
+		}
+		conn.SetDeadline(time.Time{})
+	}()
+
+	if _, err := conn.Write([]byte{socks5Version, 1, socks5AuthUserPass}); err != nil {
+		return fmt.Errorf("proxyhat: socks5: %w", err)
+	}
+	var reply [2]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return fmt.Errorf("proxyhat: socks5: %w", err)
+	}
+	if reply[0] != socks5Version {
+		return fmt.Errorf("proxyhat: socks5: unexpected protocol version %d", reply[0])
+	}
+	if reply[1] != socks5AuthUserPass {
+		return errors.New("proxyhat: socks5: gateway does not accept username/password authentication")
+	}
+
+	auth := []byte{socks5UserPassVersion, byte(len(d.endpoint.Username))}
+	auth = append(auth, d.endpoint.Username...)
+	auth = append(auth, byte(len(d.endpoint.Password)))
+	auth = append(auth, d.endpoint.Password...)
+	if _, err := conn.Write(auth); err != nil {
+		return fmt.Errorf("proxyhat: socks5: %w", err)
+	}
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return fmt.Errorf("proxyhat: socks5: %w", err)
+	}
+	if reply[1] != 0x00 {
+		return errors.New("proxyhat: socks5: authentication failed")
+	}
+
+	req := []byte{socks5Version, socks5CmdConnect, 0x00}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			req = append(req, socks5AddrIPv4)
+			req = append(req, ip4...)
+		} else {
+			req = append(req, socks5AddrIPv6)
+			req = append(req, ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return errors.New("proxyhat: socks5: host name too long")
+		}
+		req = append(req, socks5AddrDomain, byte(len(host)))
+		req = append(req, host...)
+	}
+	req = binary.BigEndian.AppendUint16(req, port)
+	if _, err := conn.Write(req); err != nil {
+		return fmt.Errorf("proxyhat: socks5: %w", err)
+	}
+
+	var head [4]byte
+	if _, err := io.ReadFull(conn, head[:]); err != nil {
+		return fmt.Errorf("proxyhat: socks5: %w", err)
+	}
+	if head[1] != 0x00 {
+		return &SOCKS5Error{Reply: head[1]}
+	}
+
+	// Discard the bound address; callers address the target, not the gateway.
+	var skip int
+	switch head[3] {
+	case socks5AddrIPv4:
+		skip = net.IPv4len
+	case socks5AddrIPv6:
+		skip = net.IPv6len
+	case socks5AddrDomain:
+		var n [1]byte
+		if _, err := io.ReadFull(conn, n[:]); err != nil {
+			return fmt.Errorf("proxyhat: socks5: %w", err)
+		}
+		skip = int(n[0])
+	default:
+		return fmt.Errorf("proxyhat: socks5: unknown address type %d", head[3])
+	}
+	if _, err := io.CopyN(io.Discard, conn, int64(skip+2)); err != nil {
+		return fmt.Errorf("proxyhat: socks5: %w", err)
+	}
+	return nil
+}
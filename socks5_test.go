@@ -0,0 +1,262 @@
+package proxyhat
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testSOCKS5Server is an in-process stand-in for the ProxyHat SOCKS5 gateway.
+type testSOCKS5Server struct {
+	ln       net.Listener
+	password string
+	reply    byte
+
+	mu        sync.Mutex
+	usernames []string
+	addrTypes []byte
+}
+
+func newTestSOCKS5Server(t *testing.T, password string) *testSOCKS5Server {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testSOCKS5Server{ln: ln, password: password}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *testSOCKS5Server) gateway() Gateway {
+	addr := s.ln.Addr().(*net.TCPAddr)
+	return Gateway{Host: addr.IP.String(), SOCKS5Port: addr.Port}
+}
+
+func (s *testSOCKS5Server) serve(conn net.Conn) {
+	defer conn.Close()
+
+	var greeting [2]byte
+	if _, err := io.ReadFull(conn, greeting[:]); err != nil {
+		return
+	}
+	methods := make([]byte, greeting[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return
+	}
+	conn.Write([]byte{socks5Version, socks5AuthUserPass})
+
+	var head [2]byte
+	if _, err := io.ReadFull(conn, head[:]); err != nil {
+		return
+	}
+	user := make([]byte, head[1])
+	io.ReadFull(conn, user)
+	var plen [1]byte
+	io.ReadFull(conn, plen[:])
+	pass := make([]byte, plen[0])
+	io.ReadFull(conn, pass)
+	if string(pass) != s.password {
+		conn.Write([]byte{socks5UserPassVersion, 0x01})
+		return
+	}
+	conn.Write([]byte{socks5UserPassVersion, 0x00})
+
+	var req [4]byte
+	if _, err := io.ReadFull(conn, req[:]); err != nil {
+		return
+	}
+	var host string
+	switch req[3] {
+	case socks5AddrIPv4:
+		ip := make([]byte, net.IPv4len)
+		io.ReadFull(conn, ip)
+		host = net.IP(ip).String()
+	case socks5AddrIPv6:
+		ip := make([]byte, net.IPv6len)
+		io.ReadFull(conn, ip)
+		host = net.IP(ip).String()
+	case socks5AddrDomain:
+		var n [1]byte
+		io.ReadFull(conn, n[:])
+		name := make([]byte, n[0])
+		io.ReadFull(conn, name)
+		host = string(name)
+	}
+	var port [2]byte
+	io.ReadFull(conn, port[:])
+
+	s.mu.Lock()
+	s.usernames = append(s.usernames, string(user))
+	s.addrTypes = append(s.addrTypes, req[3])
+	s.mu.Unlock()
+
+	if s.reply != 0 {
+		conn.Write([]byte{socks5Version, s.reply, 0x00, socks5AddrIPv4, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port[:])))))
+	if err != nil {
+		conn.Write([]byte{socks5Version, 0x05, 0x00, socks5AddrIPv4, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer target.Close()
+	conn.Write([]byte{socks5Version, 0x00, 0x00, socks5AddrIPv4, 127, 0, 0, 1, 0x04, 0x38})
+	go io.Copy(target, conn)
+	io.Copy(conn, target)
+}
+
+func TestSOCKS5Dialer_RemoteDNS(t *testing.T) {
+	srv := newTestSOCKS5Server(t, "secret")
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "via socks")
+	}))
+	defer target.Close()
+	_, port, _ := net.SplitHostPort(target.Listener.Addr().String())
+
+	dialer, err := NewSOCKS5Dialer(&SubUser{ProxyUsername: "user1"}, "secret", Targeting{Country: "FR"},
+		WithGateway(srv.gateway()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: &http.Transport{DialContext: dialer.DialContext}}
+	resp, err := client.Get("http://localhost:" + port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "via socks" {
+		t.Errorf("body = %q, want %q", body, "via socks")
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.usernames[0] != "user1-country-fr" {
+		t.Errorf("username = %q, want %q", srv.usernames[0], "user1-country-fr")
+	}
+	if srv.addrTypes[0] != socks5AddrDomain {
+		t.Errorf("address type = %d, want domain", srv.addrTypes[0])
+	}
+}
+
+func TestSOCKS5Dialer_LocalDNS(t *testing.T) {
+	srv := newTestSOCKS5Server(t, "secret")
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			io.WriteString(conn, "pong")
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	dialer, err := NewSOCKS5Dialer(&SubUser{ProxyUsername: "user1"}, "secret", Targeting{},
+		WithGateway(srv.gateway()), WithLocalDNS(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := dialer.DialContext(context.Background(), "tcp4", "localhost:"+port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	got, _ := io.ReadAll(conn)
+	if string(got) != "pong" {
+		t.Errorf("read %q, want %q", got, "pong")
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.addrTypes[0] != socks5AddrIPv4 {
+		t.Errorf("address type = %d, want IPv4", srv.addrTypes[0])
+	}
+}
+
+func TestSOCKS5Dialer_AuthFailure(t *testing.T) {
+	srv := newTestSOCKS5Server(t, "secret")
+	dialer, err := NewSOCKS5Dialer(&SubUser{ProxyUsername: "user1"}, "wrong", Targeting{},
+		WithGateway(srv.gateway()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dialer.Dial("tcp", "example.com:80"); err == nil {
+		t.Fatal("expected authentication error")
+	}
+}
+
+func TestSOCKS5Dialer_ReplyError(t *testing.T) {
+	srv := newTestSOCKS5Server(t, "secret")
+	srv.reply = 0x02
+	dialer, err := NewSOCKS5Dialer(&SubUser{ProxyUsername: "user1"}, "secret", Targeting{},
+		WithGateway(srv.gateway()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = dialer.Dial("tcp", "example.com:80")
+	var se *SOCKS5Error
+	if !errors.As(err, &se) || se.Reply != 0x02 {
+		t.Fatalf("err = %v, want SOCKS5Error with reply 2", err)
+	}
+}
+
+func TestSOCKS5Dialer_ContextCanceled(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		// Accept and never answer the greeting.
+		conn, err := ln.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(2 * time.Second)
+		}
+	}()
+	addr := ln.Addr().(*net.TCPAddr)
+
+	dialer, err := NewSOCKS5Dialer(&SubUser{ProxyUsername: "user1"}, "secret", Targeting{},
+		WithGateway(Gateway{Host: addr.IP.String(), SOCKS5Port: addr.Port}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = dialer.DialContext(ctx, "tcp", "example.com:80")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestSOCKS5Dialer_UnsupportedNetwork(t *testing.T) {
+	dialer, err := NewSOCKS5Dialer(&SubUser{ProxyUsername: "user1"}, "secret", Targeting{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dialer.Dial("udp", "example.com:53"); err == nil {
+		t.Fatal("expected error for udp")
+	}
+}
@@ -20,7 +20,7 @@ type UpdateSubUserGroupParams struct {
 // List returns all sub-user groups.
 func (s *SubUserGroupsService) List(ctx context.Context) ([]SubUserGroup, error) {
 	var result []SubUserGroup
-	err := s.client.doRequest(ctx, "GET", "sub-user-groups", nil, &result)
+	err := s.client.doRequest(ctx, "SubUserGroups.List", "GET", "sub-user-groups", nil, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -30,7 +30,7 @@ func (s *SubUserGroupsService) List(ctx context.Context) ([]SubUserGroup, error)
 // Create creates a new sub-user group.
 func (s *SubUserGroupsService) Create(ctx context.Context, params CreateSubUserGroupParams) (*SubUserGroup, error) {
 	var result SubUserGroup
-	err := s.client.doRequest(ctx, "POST", "sub-user-groups", params, &result)
+	err := s.client.doRequest(ctx, "SubUserGroups.Create", "POST", "sub-user-groups", params, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -40,7 +40,11 @@ func (s *SubUserGroupsService) Create(ctx context.Context, params CreateSubUserG
 // Get returns a sub-user group by ID.
 func (s *SubUserGroupsService) Get(ctx context.Context, id string) (*SubUserGroup, error) {
 	var result SubUserGroup
-	err := s.client.doRequest(ctx, "GET", "sub-user-groups/"+id, nil, &result)
+	path, err := buildPath("sub-user-groups/{id}", id)
+	if err != nil {
+		return nil, err
+	}
+	err = s.client.doRequest(ctx, "SubUserGroups.Get", "GET", path, nil, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -50,7 +54,11 @@ func (s *SubUserGroupsService) Get(ctx context.Context, id string) (*SubUserGrou
 // Update modifies a sub-user group.
 func (s *SubUserGroupsService) Update(ctx context.Context, id string, params UpdateSubUserGroupParams) (*SubUserGroup, error) {
 	var result SubUserGroup
-	err := s.client.doRequest(ctx, "PUT", "sub-user-groups/"+id, params, &result)
+	path, err := buildPath("sub-user-groups/{id}", id)
+	if err != nil {
+		return nil, err
+	}
+	err = s.client.doRequest(ctx, "SubUserGroups.Update", "PUT", path, params, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -59,5 +67,9 @@ func (s *SubUserGroupsService) Update(ctx context.Context, id string, params Upd
 
 // Delete removes a sub-user group.
 func (s *SubUserGroupsService) Delete(ctx context.Context, id string) error {
-	return s.client.doRequest(ctx, "DELETE", "sub-user-groups/"+id, nil, nil)
+	path, err := buildPath("sub-user-groups/{id}", id)
+	if err != nil {
+		return err
+	}
+	return s.client.doRequest(ctx, "SubUserGroups.Delete", "DELETE", path, nil, nil)
 }
@@ -24,10 +24,21 @@ type UpdateSubUserParams struct {
 	Notes            *string `json:"notes,omitempty"`
 }
 
+// path returns the path of the sub-user id, checking that id is a UUID
+// under WithUUIDValidation.
+func (s *SubUsersService) path(id string) (string, error) {
+	if s.client.checkUUIDs {
+		if err := checkUUID("id", id); err != nil {
+			return "", err
+		}
+	}
+	return buildPath("sub-users/{id}", id)
+}
+
 // List returns all sub-users.
 func (s *SubUsersService) List(ctx context.Context) ([]SubUser, error) {
 	var result []SubUser
-	err := s.client.doRequest(ctx, "GET", "sub-users", nil, &result)
+	err := s.client.doRequest(ctx, "SubUsers.List", "GET", "sub-users", nil, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -37,7 +48,7 @@ func (s *SubUsersService) List(ctx context.Context) ([]SubUser, error) {
 // Create creates a new sub-user.
 func (s *SubUsersService) Create(ctx context.Context, params CreateSubUserParams) (*SubUser, error) {
 	var result SubUser
-	err := s.client.doRequest(ctx, "POST", "sub-users", params, &result)
+	err := s.client.doRequest(ctx, "SubUsers.Create", "POST", "sub-users", params, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -47,7 +58,11 @@ func (s *SubUsersService) Create(ctx context.Context, params CreateSubUserParams
 // Get returns a sub-user by ID.
 func (s *SubUsersService) Get(ctx context.Context, id string) (*SubUser, error) {
 	var result SubUser
-	err := s.client.doRequest(ctx, "GET", "sub-users/"+id, nil, &result)
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	err = s.client.doRequest(ctx, "SubUsers.Get", "GET", path, nil, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -57,7 +72,11 @@ func (s *SubUsersService) Get(ctx context.Context, id string) (*SubUser, error)
 // Update modifies a sub-user.
 func (s *SubUsersService) Update(ctx context.Context, id string, params UpdateSubUserParams) (*SubUser, error) {
 	var result SubUser
-	err := s.client.doRequest(ctx, "PUT", "sub-users/"+id, params, &result)
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	err = s.client.doRequest(ctx, "SubUsers.Update", "PUT", path, params, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -66,14 +85,18 @@ func (s *SubUsersService) Update(ctx context.Context, id string, params UpdateSu
 
 // Delete removes a sub-user.
 func (s *SubUsersService) Delete(ctx context.Context, id string) error {
-	return s.client.doRequest(ctx, "DELETE", "sub-users/"+id, nil, nil)
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	return s.client.doRequest(ctx, "SubUsers.Delete", "DELETE", path, nil, nil)
 }
 
 // ResetUsage resets traffic usage for the given sub-user IDs.
 func (s *SubUsersService) ResetUsage(ctx context.Context, ids []string) (*ResetUsageResponse, error) {
 	var result ResetUsageResponse
 	body := map[string]any{"ids": ids}
-	err := s.client.doRequest(ctx, "POST", "sub-users/reset/usage", body, &result)
+	err := s.client.doRequest(ctx, "SubUsers.ResetUsage", "POST", "sub-users/reset/usage", body, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -84,7 +107,7 @@ func (s *SubUsersService) ResetUsage(ctx context.Context, ids []string) (*ResetU
 func (s *SubUsersService) BulkDelete(ctx context.Context, ids []string) (*BulkDeleteResponse, error) {
 	var result BulkDeleteResponse
 	body := map[string]any{"ids": ids}
-	err := s.client.doRequest(ctx, "POST", "sub-users/bulk-delete", body, &result)
+	err := s.client.doRequest(ctx, "SubUsers.BulkDelete", "POST", "sub-users/bulk-delete", body, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -98,7 +121,7 @@ func (s *SubUsersService) BulkMoveToGroup(ctx context.Context, ids []string, gro
 	if groupID != nil {
 		body["group_id"] = *groupID
 	}
-	err := s.client.doRequest(ctx, "POST", "sub-users/bulk-move-to-group", body, &result)
+	err := s.client.doRequest(ctx, "SubUsers.BulkMoveToGroup", "POST", "sub-users/bulk-move-to-group", body, &result)
 	if err != nil {
 		return nil, err
 	}
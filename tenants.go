@@ -0,0 +1,314 @@
+package proxyhat
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TenantConfig is the configuration of a multi-tenant forwarder, usually
+// loaded from a JSON file:
+//
+//	{
+//	  "tenants": [{
+//	    "name": "search-team",
+//	    "local_username": "search",
+//	    "local_password": "local-secret",
+//	    "proxy_username": "user1",
+//	    "proxy_password": "proxy-pass",
+//	    "targeting": {"country": "US", "connection_type": "mobile"},
+//	    "max_concurrent": 50
+//	  }]
+//	}
+type TenantConfig struct {
+	Tenants []Tenant `json:"tenants"`
+}
+
+// Tenant maps local credentials to a ProxyHat sub-user and default targeting.
+type Tenant struct {
+	Name          string    `json:"name"`
+	LocalUsername string    `json:"local_username"`
+	LocalPassword string    `json:"local_password"`
+	ProxyUsername string    `json:"proxy_username"`
+	ProxyPassword string    `json:"proxy_password"`
+	Targeting     Targeting `json:"targeting"`
+	// MaxConcurrent limits simultaneous requests and tunnels. Zero means no limit.
+	MaxConcurrent int `json:"max_concurrent,omitempty"`
+}
+
+// TenantStats are the per-tenant counters kept by a TenantRegistry.
+type TenantStats struct {
+	Name          string `json:"name"`
+	Requests      int64  `json:"requests"`
+	Rejected      int64  `json:"rejected"`
+	Active        int64  `json:"active"`
+	BytesSent     int64  `json:"bytes_sent"`
+	BytesReceived int64  `json:"bytes_received"`
+}
+
+type tenantStats struct {
+	requests atomic.Int64
+	rejected atomic.Int64
+	active   atomic.Int64
+	sent     atomic.Int64
+	received atomic.Int64
+}
+
+// acquire reserves a concurrency slot, counting the request either way.
+func (s *tenantStats) acquire(limit int) bool {
+	s.requests.Add(1)
+	if n := s.active.Add(1); limit > 0 && n > int64(limit) {
+		s.active.Add(-1)
+		s.rejected.Add(1)
+		return false
+	}
+	return true
+}
+
+func (s *tenantStats) release() {
+	s.active.Add(-1)
+}
+
+func (s *tenantStats) sentCounter() *atomic.Int64 {
+	if s == nil {
+		return nil
+	}
+	return &s.sent
+}
+
+func (s *tenantStats) receivedCounter() *atomic.Int64 {
+	if s == nil {
+		return nil
+	}
+	return &s.received
+}
+
+type tenantRoute struct {
+	name          string
+	password      string
+	maxConcurrent int
+	transport     *http.Transport
+	stats         *tenantStats
+}
+
+// TenantRegistry resolves local credentials to tenants and keeps their
+// counters. Counters survive reloads for tenants whose name is unchanged.
+// It is safe for concurrent use.
+type TenantRegistry struct {
+	path     string
+	upstream []GatewayOption
+	cfg      *gatewayConfig
+
+	mu      sync.RWMutex
+	modTime time.Time
+	routes  map[string]*tenantRoute
+	stats   map[string]*tenantStats
+}
+
+// NewTenantRegistry creates a registry from an in-memory configuration.
+func NewTenantRegistry(cfg TenantConfig, opts ...GatewayOption) (*TenantRegistry, error) {
+	r := &TenantRegistry{
+		upstream: opts,
+		cfg:      newGatewayConfig(opts),
+		stats:    make(map[string]*tenantStats),
+	}
+	if err := r.Update(cfg); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// LoadTenantRegistry creates a registry from a JSON configuration file.
+// Reload and Watch re-read the same file.
+func LoadTenantRegistry(path string, opts ...GatewayOption) (*TenantRegistry, error) {
+	r := &TenantRegistry{
+		path:     path,
+		upstream: opts,
+		cfg:      newGatewayConfig(opts),
+		stats:    make(map[string]*tenantStats),
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+var errNoTenantFile = errors.New("proxyhat: tenant registry has no configuration file")
+
+// Reload re-reads the configuration file. On error the previous
+// configuration stays active.
+func (r *TenantRegistry) Reload() error {
+	if r.path == "" {
+		return errNoTenantFile
+	}
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return err
+	}
+	var cfg TenantConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("proxyhat: invalid tenant configuration %s: %w", r.path, err)
+	}
+	if err := r.Update(cfg); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.modTime = info.ModTime()
+	r.mu.Unlock()
+	return nil
+}
+
+// Watch polls the configuration file every interval and reloads it when it
+// changes, until ctx is done. Reload errors are passed to onError, which
+// may be nil. Watch returns an error at once for a registry created
+// without a file, and nil when ctx is done.
+func (r *TenantRegistry) Watch(ctx context.Context, interval time.Duration, onError func(error)) error {
+	if r.path == "" {
+		return errNoTenantFile
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		info, err := os.Stat(r.path)
+		if err == nil {
+			r.mu.RLock()
+			changed := !info.ModTime().Equal(r.modTime)
+			r.mu.RUnlock()
+			if !changed {
+				continue
+			}
+			err = r.Reload()
+		}
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
+
+// Update replaces the active configuration. On error the previous
+// configuration stays active.
+func (r *TenantRegistry) Update(cfg TenantConfig) error {
+	routes := make(map[string]*tenantRoute, len(cfg.Tenants))
+	names := make(map[string]bool, len(cfg.Tenants))
+	for i, t := range cfg.Tenants {
+		if t.Name == "" || t.LocalUsername == "" || t.LocalPassword == "" {
+			return fmt.Errorf("proxyhat: tenant %d: name, local_username and local_password are required", i)
+		}
+		if names[t.Name] {
+			return fmt.Errorf("proxyhat: duplicate tenant name %q", t.Name)
+		}
+		if _, ok := routes[t.LocalUsername]; ok {
+			return fmt.Errorf("proxyhat: duplicate local_username %q", t.LocalUsername)
+		}
+		transport, err := NewProxyTransport(&SubUser{ProxyUsername: t.ProxyUsername}, t.ProxyPassword, t.Targeting, r.upstream...)
+		if err != nil {
+			return fmt.Errorf("proxyhat: tenant %q: %w", t.Name, err)
+		}
+		names[t.Name] = true
+		routes[t.LocalUsername] = &tenantRoute{
+			name:          t.Name,
+			password:      t.LocalPassword,
+			maxConcurrent: t.MaxConcurrent,
+			transport:     transport,
+		}
+	}
+
+	r.mu.Lock()
+	old := r.routes
+	for _, route := range routes {
+		stats, ok := r.stats[route.name]
+		if !ok {
+			stats = &tenantStats{}
+			r.stats[route.name] = stats
+		}
+		route.stats = stats
+	}
+	for name := range r.stats {
+		if !names[name] {
+			delete(r.stats, name)
+		}
+	}
+	r.routes = routes
+	r.mu.Unlock()
+
+	for _, route := range old {
+		route.transport.CloseIdleConnections()
+	}
+	return nil
+}
+
+func (r *TenantRegistry) authenticate(username, password string) (*tenantRoute, bool) {
+	r.mu.RLock()
+	route, ok := r.routes[username]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	if subtle.ConstantTimeCompare([]byte(password), []byte(route.password)) != 1 {
+		return nil, false
+	}
+	return route, true
+}
+
+// Stats returns a snapshot of the counters of every tenant, sorted by name.
+func (r *TenantRegistry) Stats() []TenantStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]TenantStats, 0, len(r.stats))
+	for name, s := range r.stats {
+		out = append(out, TenantStats{
+			Name:          name,
+			Requests:      s.requests.Load(),
+			Rejected:      s.rejected.Load(),
+			Active:        s.active.Load(),
+			BytesSent:     s.sent.Load(),
+			BytesReceived: s.received.Load(),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// AdminHandler returns an http.Handler for operators. GET /stats returns the
+// tenant counters as JSON and POST /reload re-reads the configuration file.
+// It performs no authentication and must only be served on a trusted address.
+func (r *TenantRegistry) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(r.Stats())
+	})
+	mux.HandleFunc("/reload", func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := r.Reload(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
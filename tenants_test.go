@@ -0,0 +1,243 @@
+package proxyhat
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func writeTenantConfig(t *testing.T, path string, cfg TenantConfig) {
+	t.Helper()
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func tenantClient(t *testing.T, fwd *httptest.Server, user, pass string) *http.Client {
+	t.Helper()
+	u, _ := url.Parse(fwd.URL)
+	u.User = url.UserPassword(user, pass)
+	return forwarderClient(t, u.String(), nil)
+}
+
+func testTenants() TenantConfig {
+	return TenantConfig{Tenants: []Tenant{
+		{
+			Name: "search", LocalUsername: "search", LocalPassword: "pw1",
+			ProxyUsername: "user1", ProxyPassword: "secret",
+			Targeting: Targeting{Country: "US"},
+		},
+		{
+			Name: "ads", LocalUsername: "ads", LocalPassword: "pw2",
+			ProxyUsername: "user2", ProxyPassword: "secret",
+			Targeting: Targeting{Country: "DE", ConnectionType: "mobile"},
+		},
+	}}
+}
+
+func TestTenantForwarder_MapsTenants(t *testing.T) {
+	gw := newTestGateway(t, "secret")
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "0123456789")
+	}))
+	defer target.Close()
+
+	reg, err := NewTenantRegistry(testTenants(), WithGateway(gw.gateway()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fwd := httptest.NewServer(NewTenantForwarder(reg))
+	defer fwd.Close()
+
+	for _, c := range []struct{ user, pass string }{{"search", "pw1"}, {"ads", "pw2"}} {
+		resp, err := tenantClient(t, fwd, c.user, c.pass).Get(target.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	resp, err := tenantClient(t, fwd, "ads", "wrong").Get(target.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusProxyAuthRequired {
+		t.Errorf("status = %d, want 407", resp.StatusCode)
+	}
+
+	seen := gw.seen()
+	if len(seen) != 2 || seen[0] != "user1-country-us" || seen[1] != "user2-country-de-type-mobile" {
+		t.Errorf("gateway usernames = %v", seen)
+	}
+
+	stats := reg.Stats()
+	if len(stats) != 2 || stats[0].Name != "ads" || stats[1].Name != "search" {
+		t.Fatalf("stats = %+v", stats)
+	}
+	if stats[1].Requests != 1 || stats[1].BytesReceived != 10 || stats[1].Active != 0 {
+		t.Errorf("search stats = %+v", stats[1])
+	}
+}
+
+func TestTenantForwarder_ConcurrencyLimit(t *testing.T) {
+	gw := newTestGateway(t, "secret")
+	release := make(chan struct{})
+	entered := make(chan struct{})
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	}))
+	defer target.Close()
+
+	cfg := testTenants()
+	cfg.Tenants[0].MaxConcurrent = 1
+	reg, err := NewTenantRegistry(cfg, WithGateway(gw.gateway()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fwd := httptest.NewServer(NewTenantForwarder(reg))
+	defer fwd.Close()
+	client := tenantClient(t, fwd, "search", "pw1")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if resp, err := client.Get(target.URL); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-entered
+
+	resp, err := client.Get(target.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429", resp.StatusCode)
+	}
+	close(release)
+	<-done
+
+	if stats := reg.Stats(); stats[1].Rejected != 1 {
+		t.Errorf("rejected = %d, want 1", stats[1].Rejected)
+	}
+}
+
+func TestTenantRegistry_ReloadKeepsStats(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	writeTenantConfig(t, path, testTenants())
+
+	reg, err := LoadTenantRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	route, ok := reg.authenticate("search", "pw1")
+	if !ok {
+		t.Fatal("search tenant not found")
+	}
+	route.stats.acquire(0)
+	route.stats.release()
+
+	cfg := testTenants()
+	cfg.Tenants[0].LocalPassword = "rotated"
+	cfg.Tenants = cfg.Tenants[:1]
+	writeTenantConfig(t, path, cfg)
+	if err := reg.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := reg.authenticate("search", "pw1"); ok {
+		t.Error("old password still accepted after reload")
+	}
+	if _, ok := reg.authenticate("search", "rotated"); !ok {
+		t.Error("new password rejected after reload")
+	}
+	stats := reg.Stats()
+	if len(stats) != 1 || stats[0].Requests != 1 {
+		t.Errorf("stats after reload = %+v", stats)
+	}
+}
+
+func TestTenantRegistry_InvalidConfigKeepsPrevious(t *testing.T) {
+	reg, err := NewTenantRegistry(testTenants())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad := testTenants()
+	bad.Tenants[1].LocalUsername = "search"
+	if err := reg.Update(bad); err == nil {
+		t.Fatal("expected duplicate local_username error")
+	}
+	if _, ok := reg.authenticate("ads", "pw2"); !ok {
+		t.Error("previous configuration lost after failed update")
+	}
+}
+
+func TestTenantRegistry_RequiresLocalPassword(t *testing.T) {
+	cfg := testTenants()
+	cfg.Tenants[0].LocalPassword = ""
+	if _, err := NewTenantRegistry(cfg); err == nil {
+		t.Fatal("expected error for a tenant without local_password")
+	}
+}
+
+func TestTenantRegistry_WatchWithoutFile(t *testing.T) {
+	reg, err := NewTenantRegistry(testTenants())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var reported atomic.Int32
+	if err := reg.Watch(ctx, time.Millisecond, func(error) { reported.Add(1) }); err == nil {
+		t.Error("Watch = nil error for a registry without a file")
+	}
+	if reported.Load() != 0 {
+		t.Errorf("onError called %d times", reported.Load())
+	}
+}
+
+func TestTenantRegistry_AdminHandler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	writeTenantConfig(t, path, testTenants())
+	reg, err := LoadTenantRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := httptest.NewServer(reg.AdminHandler())
+	defer admin.Close()
+
+	resp, err := http.Get(admin.URL + "/stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stats []TenantStats
+	json.NewDecoder(resp.Body).Decode(&stats)
+	resp.Body.Close()
+	if len(stats) != 2 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	resp, err = http.Post(admin.URL+"/reload", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("reload status = %d, want 204", resp.StatusCode)
+	}
+}
@@ -0,0 +1,151 @@
+package proxyhat
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Tracer starts spans around API operations. It mirrors the small part of
+// the OpenTelemetry API the SDK needs, so that tracing carries no
+// dependency; the otelproxyhat module adapts an OpenTelemetry
+// TracerProvider to it.
+type Tracer interface {
+	// Start starts a span and returns a context carrying it.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is an operation being traced. Its methods may be called from
+// several goroutines.
+type Span interface {
+	SetAttributes(attrs ...slog.Attr)
+	AddEvent(name string, attrs ...slog.Attr)
+	RecordError(err error)
+	End()
+	// TraceParent returns the W3C traceparent header value identifying
+	// the span, or "" if the span is not recording or has no trace context.
+	TraceParent() string
+}
+
+// WithTracer wraps every API call in a span named after the SDK method,
+// e.g. "SubUsers.Create", and propagates it to the API in the traceparent
+// header. Connection timings are recorded as span events.
+func WithTracer(t Tracer) Option {
+	return func(c *Client) {
+		c.tracer = t
+	}
+}
+
+func tracingMiddleware(t Tracer) Middleware {
+	return func(next Handler) Handler {
+		return func(req *Request) (*Response, error) {
+			name := req.Operation
+			if name == "" {
+				name = req.Method + " " + metricsEndpoint(req.Path)
+			}
+			ctx, span := t.Start(req.Context(), name)
+			defer span.End()
+			span.SetAttributes(
+				slog.String("http.request.method", req.Method),
+				slog.String("url.path", req.Path),
+			)
+
+			req = req.WithContext(httptrace.WithClientTrace(ctx, NewClientTrace(span)))
+			if tp := span.TraceParent(); tp != "" {
+				req.Header = req.Header.Clone()
+				if req.Header == nil {
+					req.Header = http.Header{}
+				}
+				req.Header.Set("Traceparent", tp)
+			}
+
+			resp, err := next(req)
+			span.SetAttributes(slog.Int("proxyhat.attempts", attempts(resp, err)))
+			if resp != nil && resp.Response != nil {
+				span.SetAttributes(slog.Int("http.response.status_code", resp.StatusCode))
+				if id := resp.Header.Get("X-Request-Id"); id != "" {
+					span.SetAttributes(slog.String("proxyhat.request_id", id))
+				}
+			}
+			if err != nil {
+				span.RecordError(err)
+			}
+			return resp, err
+		}
+	}
+}
+
+// NewClientTrace returns an httptrace.ClientTrace that records DNS lookup,
+// connection, TLS handshake and first-byte timings as events on span.
+// WithTracer installs it automatically; use it directly to trace other
+// requests, such as those sent through NewProxyTransport.
+func NewClientTrace(span Span) *httptrace.ClientTrace {
+	// Dial attempts to several addresses may run concurrently.
+	var mu sync.Mutex
+	starts := map[string]time.Time{}
+	start := func(key string) {
+		mu.Lock()
+		starts[key] = time.Now()
+		mu.Unlock()
+	}
+	since := func(key string) slog.Attr {
+		mu.Lock()
+		defer mu.Unlock()
+		return slog.Duration("duration", time.Since(starts[key]))
+	}
+	return &httptrace.ClientTrace{
+		GetConn: func(hostPort string) {
+			span.AddEvent("get_conn", slog.String("host", hostPort))
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			span.AddEvent("got_conn", slog.Bool("reused", info.Reused), slog.Bool("was_idle", info.WasIdle))
+		},
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			start("dns")
+			span.AddEvent("dns_start", slog.String("host", info.Host))
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			attrs := []slog.Attr{since("dns")}
+			if info.Err != nil {
+				attrs = append(attrs, slog.String("error", info.Err.Error()))
+			}
+			span.AddEvent("dns_done", attrs...)
+		},
+		ConnectStart: func(network, addr string) {
+			start("connect " + addr)
+			span.AddEvent("connect_start", slog.String("network", network), slog.String("addr", addr))
+		},
+		ConnectDone: func(network, addr string, err error) {
+			attrs := []slog.Attr{slog.String("addr", addr), since("connect " + addr)}
+			if err != nil {
+				attrs = append(attrs, slog.String("error", err.Error()))
+			}
+			span.AddEvent("connect_done", attrs...)
+		},
+		TLSHandshakeStart: func() {
+			start("tls")
+			span.AddEvent("tls_handshake_start")
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			attrs := []slog.Attr{since("tls"), slog.String("version", tls.VersionName(state.Version))}
+			if err != nil {
+				attrs = append(attrs, slog.String("error", err.Error()))
+			}
+			span.AddEvent("tls_handshake_done", attrs...)
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			if info.Err != nil {
+				span.AddEvent("wrote_request", slog.String("error", info.Err.Error()))
+				return
+			}
+			span.AddEvent("wrote_request")
+		},
+		GotFirstResponseByte: func() {
+			span.AddEvent("first_response_byte")
+		},
+	}
+}
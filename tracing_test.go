@@ -0,0 +1,115 @@
+package proxyhat
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+type testSpan struct {
+	name string
+
+	mu     sync.Mutex
+	attrs  map[string]slog.Value
+	events []string
+	errs   []error
+	ended  bool
+}
+
+func (s *testSpan) SetAttributes(attrs ...slog.Attr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range attrs {
+		s.attrs[a.Key] = a.Value
+	}
+}
+
+func (s *testSpan) AddEvent(name string, attrs ...slog.Attr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, name)
+}
+
+func (s *testSpan) RecordError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errs = append(s.errs, err)
+}
+
+func (s *testSpan) End() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ended = true
+}
+
+func (s *testSpan) TraceParent() string {
+	return "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+}
+
+func (s *testSpan) hasEvent(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.events {
+		if e == name {
+			return true
+		}
+	}
+	return false
+}
+
+type testTracer struct {
+	mu    sync.Mutex
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	span := &testSpan{name: name, attrs: map[string]slog.Value{}}
+	t.mu.Lock()
+	t.spans = append(t.spans, span)
+	t.mu.Unlock()
+	return ctx, span
+}
+
+func TestWithTracer(t *testing.T) {
+	tracer := &testTracer{}
+	client, mux, cleanup := setupTest(WithTracer(tracer))
+	defer cleanup()
+	mux.HandleFunc("/sub-users", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Traceparent"); got != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+			t.Errorf("traceparent = %q", got)
+		}
+		w.Header().Set("X-Request-Id", "req-1")
+		writePayload(w, SubUser{})
+	})
+	mux.HandleFunc("/payments/pay-1/invoice", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "not found"})
+	})
+
+	if _, err := client.SubUsers.Create(context.Background(), CreateSubUserParams{}); err != nil {
+		t.Fatal(err)
+	}
+	client.Payments.Invoice(context.Background(), "pay-1", "pdf")
+
+	if len(tracer.spans) != 2 {
+		t.Fatalf("spans = %d, want 2", len(tracer.spans))
+	}
+	create := tracer.spans[0]
+	if create.name != "SubUsers.Create" || !create.ended || len(create.errs) != 0 {
+		t.Errorf("span = %+v", create)
+	}
+	if create.attrs["http.response.status_code"].Int64() != 200 || create.attrs["proxyhat.request_id"].String() != "req-1" {
+		t.Errorf("attrs = %v", create.attrs)
+	}
+	for _, event := range []string{"get_conn", "connect_done", "got_conn", "first_response_byte"} {
+		if !create.hasEvent(event) {
+			t.Errorf("missing event %q in %v", event, create.events)
+		}
+	}
+
+	invoice := tracer.spans[1]
+	if invoice.name != "Payments.Invoice" || len(invoice.errs) != 1 || !IsNotFoundError(invoice.errs[0]) {
+		t.Errorf("span = %+v", invoice)
+	}
+}
@@ -0,0 +1,139 @@
+package proxyhat
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	DefaultDialTimeout         = 10 * time.Second
+	DefaultTLSHandshakeTimeout = 10 * time.Second
+)
+
+// GatewayOption configures the data-plane helpers that connect through the
+// ProxyHat gateway.
+type GatewayOption func(*gatewayConfig)
+
+type gatewayConfig struct {
+	gateway             Gateway
+	dialTimeout         time.Duration
+	tlsHandshakeTimeout time.Duration
+	tlsConfig           *tls.Config
+	resolver            *net.Resolver
+	sessions            *SessionManager
+	router              *Router
+}
+
+func newGatewayConfig(opts []GatewayOption) *gatewayConfig {
+	cfg := &gatewayConfig{
+		gateway:             DefaultGateway(),
+		dialTimeout:         DefaultDialTimeout,
+		tlsHandshakeTimeout: DefaultTLSHandshakeTimeout,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	cfg.gateway = cfg.gateway.withDefaults()
+	return cfg
+}
+
+// WithGateway sets the gateway to connect through.
+func WithGateway(g Gateway) GatewayOption {
+	return func(c *gatewayConfig) {
+		c.gateway = g
+	}
+}
+
+// WithDialTimeout sets the timeout for establishing a connection to the gateway.
+func WithDialTimeout(d time.Duration) GatewayOption {
+	return func(c *gatewayConfig) {
+		c.dialTimeout = d
+	}
+}
+
+// WithTLSHandshakeTimeout sets the timeout for TLS handshakes with target hosts.
+func WithTLSHandshakeTimeout(d time.Duration) GatewayOption {
+	return func(c *gatewayConfig) {
+		c.tlsHandshakeTimeout = d
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used for HTTPS target hosts.
+func WithTLSConfig(tc *tls.Config) GatewayOption {
+	return func(c *gatewayConfig) {
+		c.tlsConfig = tc
+	}
+}
+
+// NewProxyTransport returns an http.Transport that sends every request
+// through the ProxyHat gateway as the given sub-user. Plain HTTP targets are
+// forwarded by the gateway and HTTPS targets are tunnelled with CONNECT.
+//
+// Idle connections are pooled per gateway username, so requests that share
+// a sticky session reuse the same connections and exit IP. Use WithSessions
+// to pick the session per request and WithRouter to pick the targeting per
+// destination host.
+//
+//	transport, err := proxyhat.NewProxyTransport(subUser, "proxy-pass", proxyhat.Targeting{Country: "US"})
+//	client := &http.Client{Transport: transport}
+func NewProxyTransport(subUser *SubUser, password string, t Targeting, opts ...GatewayOption) (*http.Transport, error) {
+	cfg := newGatewayConfig(opts)
+	endpoint, err := cfg.gateway.HTTPEndpoint(subUser, password, t)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.sessions == nil && cfg.router == nil {
+		proxyURL := endpoint.URL()
+		return cfg.transport(func(*http.Request) (*url.URL, error) {
+			return proxyURL, nil
+		}), nil
+	}
+	return cfg.transport(cfg.proxyFunc(subUser, password, t)), nil
+}
+
+// proxyFunc returns a Proxy function that resolves the gateway URL per
+// request: routing rules pick the targeting, or a direct connection, and the
+// session manager pins it to the request's session.
+func (c *gatewayConfig) proxyFunc(subUser *SubUser, password string, base Targeting) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		t := base
+		if c.router != nil {
+			if route, ok := c.router.Route(req.URL.Hostname()); ok {
+				if route.Direct {
+					return nil, nil
+				}
+				t = route.Targeting
+			}
+		}
+		if c.sessions != nil {
+			if key, ok := SessionKeyFromContext(req.Context()); ok {
+				t = c.sessions.Targeting(key, t)
+			}
+		}
+		endpoint, err := c.gateway.HTTPEndpoint(subUser, password, t)
+		if err != nil {
+			return nil, err
+		}
+		return endpoint.URL(), nil
+	}
+}
+
+func (c *gatewayConfig) transport(proxy func(*http.Request) (*url.URL, error)) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   c.dialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       c.tlsConfig.Clone(),
+		TLSHandshakeTimeout:   c.tlsHandshakeTimeout,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
@@ -0,0 +1,200 @@
+package proxyhat
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testGateway is an in-process stand-in for the ProxyHat HTTP gateway. It
+// checks proxy credentials, forwards plain HTTP requests and tunnels CONNECT.
+type testGateway struct {
+	*httptest.Server
+	password string
+
+	mu        sync.Mutex
+	usernames []string
+	connects  int
+}
+
+func newTestGateway(t *testing.T, password string) *testGateway {
+	t.Helper()
+	g := &testGateway{password: password}
+	g.Server = httptest.NewServer(g)
+	t.Cleanup(g.Close)
+	return g
+}
+
+func (g *testGateway) gateway() Gateway {
+	u, _ := url.Parse(g.URL)
+	port, _ := strconv.Atoi(u.Port())
+	return Gateway{Host: u.Hostname(), HTTPPort: port}
+}
+
+func (g *testGateway) seen() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]string(nil), g.usernames...)
+}
+
+func (g *testGateway) connectCount() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.connects
+}
+
+func (g *testGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	user, pass, ok := proxyBasicAuth(r)
+	if !ok || pass != g.password {
+		w.Header().Set("Proxy-Authenticate", `Basic realm="proxyhat"`)
+		w.WriteHeader(http.StatusProxyAuthRequired)
+		return
+	}
+	g.mu.Lock()
+	g.usernames = append(g.usernames, user)
+	if r.Method == http.MethodConnect {
+		g.connects++
+	}
+	g.mu.Unlock()
+
+	if r.Method == http.MethodConnect {
+		upstream, err := net.DialTimeout("tcp", r.Host, 5*time.Second)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			upstream.Close()
+			return
+		}
+		io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n")
+		go func() {
+			io.Copy(upstream, conn)
+			upstream.Close()
+		}()
+		io.Copy(conn, upstream)
+		conn.Close()
+		return
+	}
+
+	out, err := http.NewRequest(r.Method, r.URL.String(), r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	for k, v := range r.Header {
+		if k != "Proxy-Authorization" {
+			out.Header[k] = v
+		}
+	}
+	resp, err := (&http.Transport{}).RoundTrip(out)
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+func TestNewProxyTransport_HTTPTarget(t *testing.T) {
+	gw := newTestGateway(t, "secret")
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Proxy-Authorization") != "" {
+			t.Error("Proxy-Authorization leaked to target")
+		}
+		io.WriteString(w, "hello")
+	}))
+	defer target.Close()
+
+	transport, err := NewProxyTransport(&SubUser{ProxyUsername: "user1"}, "secret", Targeting{Country: "US"},
+		WithGateway(gw.gateway()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&http.Client{Transport: transport}).Get(target.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "hello" {
+		t.Errorf("body = %q, want %q", body, "hello")
+	}
+	if got := gw.seen(); len(got) != 1 || got[0] != "user1-country-us" {
+		t.Errorf("gateway usernames = %v", got)
+	}
+}
+
+func TestNewProxyTransport_HTTPSTargetReusesTunnel(t *testing.T) {
+	gw := newTestGateway(t, "secret")
+	target := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "secure")
+	}))
+	defer target.Close()
+
+	targeting := Targeting{Country: "DE"}.WithSession("s1", 0)
+	transport, err := NewProxyTransport(&SubUser{ProxyUsername: "user1"}, "secret", targeting,
+		WithGateway(gw.gateway()),
+		WithTLSConfig(target.Client().Transport.(*http.Transport).TLSClientConfig),
+		WithDialTimeout(time.Second),
+		WithTLSHandshakeTimeout(time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: transport}
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(target.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "secure" {
+			t.Errorf("body = %q, want %q", body, "secure")
+		}
+	}
+
+	if got := gw.connectCount(); got != 1 {
+		t.Errorf("CONNECT count = %d, want 1", got)
+	}
+	if got := gw.seen(); got[0] != "user1-country-de-session-s1" {
+		t.Errorf("gateway username = %q", got[0])
+	}
+}
+
+func TestNewProxyTransport_BadCredentials(t *testing.T) {
+	gw := newTestGateway(t, "secret")
+	transport, err := NewProxyTransport(&SubUser{ProxyUsername: "user1"}, "wrong", Targeting{},
+		WithGateway(gw.gateway()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&http.Client{Transport: transport}).Get("http://example.invalid/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusProxyAuthRequired {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusProxyAuthRequired)
+	}
+}
+
+func TestNewProxyTransport_InvalidTargeting(t *testing.T) {
+	_, err := NewProxyTransport(&SubUser{ProxyUsername: "user1"}, "secret", Targeting{Country: "u:s"})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
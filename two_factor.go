@@ -10,7 +10,7 @@ type TwoFactorService struct {
 // Status returns the 2FA status for the authenticated user.
 func (s *TwoFactorService) Status(ctx context.Context) (*TwoFactorStatus, error) {
 	var result TwoFactorStatus
-	err := s.client.doRequest(ctx, "GET", "profile/2fa/status", nil, &result)
+	err := s.client.doRequest(ctx, "TwoFactor.Status", "GET", "profile/2fa/status", nil, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -20,18 +20,25 @@ func (s *TwoFactorService) Status(ctx context.Context) (*TwoFactorStatus, error)
 // Enable initiates 2FA setup.
 func (s *TwoFactorService) Enable(ctx context.Context) (*TwoFactorEnableResponse, error) {
 	var result TwoFactorEnableResponse
-	err := s.client.doRequest(ctx, "POST", "profile/2fa/enable", nil, &result)
+	err := s.client.doRequest(ctx, "TwoFactor.Enable", "POST", "profile/2fa/enable", nil, &result)
 	if err != nil {
 		return nil, err
 	}
 	return &result, nil
 }
 
+// twoFactorConfirmBody is the body of TwoFactorService.Confirm. It has its
+// own type so that Redact can mask the code without hiding every other
+// "code" field, such as country codes.
+type twoFactorConfirmBody struct {
+	Code string `json:"code"`
+}
+
 // Confirm confirms 2FA setup with a verification code.
 func (s *TwoFactorService) Confirm(ctx context.Context, code string) (any, error) {
 	var result any
-	body := map[string]string{"code": code}
-	err := s.client.doRequest(ctx, "POST", "profile/2fa/confirm", body, &result)
+	body := twoFactorConfirmBody{Code: code}
+	err := s.client.doRequest(ctx, "TwoFactor.Confirm", "POST", "profile/2fa/confirm", body, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -42,7 +49,7 @@ func (s *TwoFactorService) Confirm(ctx context.Context, code string) (any, error
 func (s *TwoFactorService) Disable(ctx context.Context, twofaCode string) (any, error) {
 	var result any
 	body := map[string]string{"twofa_code": twofaCode}
-	err := s.client.doRequest(ctx, "POST", "profile/2fa/disable", body, &result)
+	err := s.client.doRequest(ctx, "TwoFactor.Disable", "POST", "profile/2fa/disable", body, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -52,7 +59,7 @@ func (s *TwoFactorService) Disable(ctx context.Context, twofaCode string) (any,
 // QRCode returns the QR code for 2FA setup.
 func (s *TwoFactorService) QRCode(ctx context.Context) (*TwoFactorEnableResponse, error) {
 	var result TwoFactorEnableResponse
-	err := s.client.doRequest(ctx, "GET", "profile/2fa/qr-code", nil, &result)
+	err := s.client.doRequest(ctx, "TwoFactor.QRCode", "GET", "profile/2fa/qr-code", nil, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -62,7 +69,7 @@ func (s *TwoFactorService) QRCode(ctx context.Context) (*TwoFactorEnableResponse
 // RecoveryCodes returns the 2FA recovery codes.
 func (s *TwoFactorService) RecoveryCodes(ctx context.Context) (*RecoveryCodes, error) {
 	var result RecoveryCodes
-	err := s.client.doRequest(ctx, "GET", "profile/2fa/recovery-codes", nil, &result)
+	err := s.client.doRequest(ctx, "TwoFactor.RecoveryCodes", "GET", "profile/2fa/recovery-codes", nil, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -73,7 +80,7 @@ func (s *TwoFactorService) RecoveryCodes(ctx context.Context) (*RecoveryCodes, e
 func (s *TwoFactorService) DisableByRecovery(ctx context.Context, recoveryCode string) (any, error) {
 	var result any
 	body := map[string]string{"recovery_code": recoveryCode}
-	err := s.client.doRequest(ctx, "POST", "profile/2fa/disable-by-recovery-code", body, &result)
+	err := s.client.doRequest(ctx, "TwoFactor.DisableByRecovery", "POST", "profile/2fa/disable-by-recovery-code", body, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -90,7 +97,7 @@ type ChangePasswordParams struct {
 // ChangePassword changes the user's password.
 func (s *TwoFactorService) ChangePassword(ctx context.Context, params ChangePasswordParams) (any, error) {
 	var result any
-	err := s.client.doRequest(ctx, "POST", "profile/password", params, &result)
+	err := s.client.doRequest(ctx, "TwoFactor.ChangePassword", "POST", "profile/password", params, &result)
 	if err != nil {
 		return nil, err
 	}